@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	embedme "github.com/romnn/embedme/pkg"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v3"
+)
+
+var addCommand = cli.Command{
+	Name:      "add",
+	Usage:     "add a snippet to the section under a heading of a markdown document and embed it",
+	ArgsUsage: "<document> <command>",
+	Flags: []cli.Flag{
+		&underFlag,
+	},
+	Action: add,
+}
+
+// flagAfterArgs removes a string flag that follows the arguments
+// (e.g. README.md --under "## Usage") and returns its value and the remaining arguments
+func flagAfterArgs(args []string, name string) (string, []string) {
+	var value string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimLeft(args[i], "-")
+		switch {
+		case arg == args[i]:
+			rest = append(rest, args[i])
+		case arg == name && i+1 < len(args):
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(arg, name+"="):
+			value = strings.TrimPrefix(arg, name+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return value, rest
+}
+
+func add(ctx context.Context, cmd *cli.Command) error {
+	heading := cmd.String(underFlag.Name)
+	args := cmd.Args().Slice()
+	if value, rest := flagAfterArgs(args, underFlag.Name); value != "" {
+		heading, args = value, rest
+	}
+	if heading == "" {
+		return fmt.Errorf("missing heading of the section to add the snippet to (e.g. --under \"## Usage\")")
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("expected a document and an embed command (e.g. README.md pkg/options.go#Options)")
+	}
+	document, command := args[0], args[1]
+
+	config, err := parseConfig(cmd)
+	if err != nil {
+		return err
+	}
+	configureOutput(config)
+
+	options, _, err := newOptions(cmd, config)
+	if err != nil {
+		return err
+	}
+	if options.DryRun || options.Verify || options.Stdout {
+		return fmt.Errorf("snippets cannot be added with --dry-run, --verify or --stdout")
+	}
+	embedder, err := embedme.NewEmbedder(options)
+	if err != nil {
+		return fmt.Errorf("failed to create new embedder: %v", err)
+	}
+
+	path := document
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(options.WorkingDir, path)
+	}
+	format := embedme.FormatForPath(path)
+	if options.Format != "" {
+		if format, err = embedme.FormatForName(options.Format); err != nil {
+			return err
+		}
+	}
+	if _, ok := format.(embedme.MarkdownFormat); !ok {
+		return fmt.Errorf("snippets can only be added to markdown documents (%s is %s)", document, format.Name())
+	}
+
+	source, err := afero.ReadFile(embedder.FS, path)
+	if err != nil {
+		return fmt.Errorf("file %s could not be read: %v", document, err)
+	}
+	updated, err := embedme.AddSnippet(
+		string(source), heading, command, embedme.Languages.With(options.Languages...),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add snippet to %s: %v", document, err)
+	}
+	// the document is only written if the snippet and all other blocks can be embedded
+	embedded, err := embedder.Embed([]byte(updated), path, document)
+	if err != nil {
+		return fmt.Errorf("failed to embed %s: %v", document, err)
+	}
+	if err := afero.WriteFile(embedder.FS, path, []byte(embedded), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", document, err)
+	}
+	embedme.Magenta(log.Writer(), "Added %s under %q in %s\n", command, heading, document)
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const optionsFixture = `package pkg
+
+// Options configures embedme
+type Options struct {
+	Verify bool
+}
+`
+
+// runInDir runs embedme with arguments in a directory
+func runInDir(t *testing.T, dir string, args ...string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+	return newApp().Run(context.Background(), append([]string{"embedme"}, args...))
+}
+
+func TestAddCommand(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "pkg"), 0755)
+	os.WriteFile(filepath.Join(dir, "pkg", "options.go"), []byte(optionsFixture), 0644)
+	readme := filepath.Join(dir, "README.md")
+	os.WriteFile(readme, []byte("# Demo\n\n## Usage\n\nSome text.\n"), 0644)
+
+	err := runInDir(t, dir, "add", "README.md", "--under", "## Usage", "pkg/options.go#Options")
+	if err != nil {
+		t.Fatalf("failed to add snippet: %v", err)
+	}
+	content, _ := os.ReadFile(readme)
+	expected := "# Demo\n\n## Usage\n\nSome text.\n\n" +
+		"<!-- embedme pkg/options.go#Options -->\n" +
+		"```go\n" +
+		"// Options configures embedme\n" +
+		"type Options struct {\n" +
+		"\tVerify bool\n" +
+		"}\n" +
+		"```\n"
+	if diff := cmp.Diff(string(content), expected); diff != "" {
+		t.Fatalf("unexpected README: %s", diff)
+	}
+}
+
+func TestAddCommandKeepsDocumentOnError(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "pkg"), 0755)
+	os.WriteFile(filepath.Join(dir, "pkg", "options.go"), []byte(optionsFixture), 0644)
+	readme := filepath.Join(dir, "README.md")
+
+	for _, c := range []struct {
+		source  string
+		command string
+	}{
+		{"# Demo\n\n## Usage\n\nSome text.\n", "pkg/options.go#Missing"},
+		{"# Demo\n\n<!-- embedme missing.go -->\n```go\n```\n\n## Usage\n", "pkg/options.go#Options"},
+	} {
+		os.WriteFile(readme, []byte(c.source), 0644)
+		if err := runInDir(t, dir, "add", "README.md", "--under", "## Usage", c.command); err == nil {
+			t.Errorf("expected adding %s to fail", c.command)
+		}
+		content, _ := os.ReadFile(readme)
+		if diff := cmp.Diff(string(content), c.source); diff != "" {
+			t.Errorf("expected README to be unchanged after adding %s: %s", c.command, diff)
+		}
+	}
+}
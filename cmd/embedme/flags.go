@@ -51,6 +51,11 @@ var (
 		Sources: cli.EnvVars(EnvPrefix + "_GLOB"),
 		Usage:   "treat arguments as patterns and glob from current directory",
 	}
+	noIgnoreFlag = cli.BoolFlag{
+		Name:    "no-ignore",
+		Sources: cli.EnvVars(EnvPrefix + "_NO_IGNORE"),
+		Usage:   "don't skip files ignored by .gitignore, .embedmeignore, .git/info/exclude or the global git excludes file",
+	}
 	stdoutFlag = cli.BoolFlag{
 		Name:    "stdout",
 		Sources: cli.EnvVars(EnvPrefix + "_STDOUT"),
@@ -67,4 +72,43 @@ var (
 		Sources: cli.EnvVars(EnvPrefix + "_STRIP_EMBED_COMMENT"),
 		Usage:   "remove the comment from the code block (only works with --stdout)",
 	}
+	formatFlag = cli.StringFlag{
+		Name:    "format",
+		Sources: cli.EnvVars(EnvPrefix + "_FORMAT"),
+		Usage:   "document format (e.g. markdown, rst, asciidoc, org or latex), detected from the file extension by default",
+	}
+	stripCalloutsFlag = cli.BoolFlag{
+		Name:    "strip-callouts",
+		Sources: cli.EnvVars(EnvPrefix + "_STRIP_CALLOUTS"),
+		Usage:   "remove AsciiDoc callouts (e.g. // <1>) from code embedded into other documents",
+	}
+	underFlag = cli.StringFlag{
+		Name:  "under",
+		Usage: "heading of the section to add the snippet to (e.g. \"## Usage\")",
+	}
+	onlyBlockFlag = cli.StringSliceFlag{
+		Name:    "only-block",
+		Sources: cli.EnvVars(EnvPrefix + "_ONLY_BLOCK"),
+		Usage:   "only embed into code blocks at a line (e.g. 42 or 10-20) or with an embed command matching a regular expression",
+	}
+	skipBlockFlag = cli.StringSliceFlag{
+		Name:    "skip-block",
+		Sources: cli.EnvVars(EnvPrefix + "_SKIP_BLOCK"),
+		Usage:   "don't embed into code blocks at a line (e.g. 42 or 10-20) or with an embed command matching a regular expression",
+	}
+	languagesFlag = cli.StringFlag{
+		Name:    "languages",
+		Sources: cli.EnvVars(EnvPrefix + "_LANGUAGES"),
+		Usage:   "YAML table of additional languages, which override known languages with the same aliases",
+	}
+	configFlag = cli.StringFlag{
+		Name:    "config",
+		Sources: cli.EnvVars(EnvPrefix + "_CONFIG"),
+		Usage:   "configuration file (default: .embedme.yaml in the working directory or its parents)",
+	}
+	profileFlag = cli.StringFlag{
+		Name:    "profile",
+		Sources: cli.EnvVars(EnvPrefix + "_PROFILE"),
+		Usage:   "profile of the configuration file (e.g. ci)",
+	}
 )
@@ -6,11 +6,13 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	embedme "github.com/romnn/embedme/pkg"
+	"github.com/spf13/afero"
 	"github.com/urfave/cli/v3"
 )
 
@@ -34,8 +36,13 @@ type config struct {
 	DryRun     bool
 	Output     string
 	Glob       bool
+	NoIgnore   bool
 	WorkingDir string
 	Base       string
+	Languages  []embedme.Language
+	OnlyBlocks []embedme.BlockSelector
+	SkipBlocks []embedme.BlockSelector
+	Settings   *embedme.ConfigSettings
 }
 
 func parseConfig(cmd *cli.Command) (config, error) {
@@ -48,6 +55,7 @@ func parseConfig(cmd *cli.Command) (config, error) {
 		DryRun:     cmd.Bool(dryRunFlag.Name),
 		Output:     cmd.String(outputFlag.Name),
 		Glob:       cmd.Bool(globFlag.Name),
+		NoIgnore:   cmd.Bool(noIgnoreFlag.Name),
 		WorkingDir: cmd.String(cwdFlag.Name),
 		Base:       cmd.String(sourceBaseFlag.Name),
 	}
@@ -58,12 +66,114 @@ func parseConfig(cmd *cli.Command) (config, error) {
 	if config.WorkingDir == "" {
 		config.WorkingDir = realWorkingDir
 	}
-	if config.Base == "" {
-		config.Base = config.WorkingDir
+	if config.Settings, err = loadSettings(cmd, config.WorkingDir); err != nil {
+		return config, err
+	}
+	if config.OnlyBlocks, err = embedme.ParseBlockSelectors(cmd.StringSlice(onlyBlockFlag.Name)); err != nil {
+		return config, err
+	}
+	if config.SkipBlocks, err = embedme.ParseBlockSelectors(cmd.StringSlice(skipBlockFlag.Name)); err != nil {
+		return config, err
+	}
+	if path := cmd.String(languagesFlag.Name); path != "" {
+		table, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("failed to read languages: %v", err)
+		}
+		if config.Languages, err = embedme.ParseLanguages(table); err != nil {
+			return config, fmt.Errorf("invalid languages %s: %v", path, err)
+		}
 	}
 	return config, nil
 }
 
+// loadSettings loads the settings of the configuration file and the selected profile
+//
+// The settings are nil if there is no configuration file.
+func loadSettings(cmd *cli.Command, workingDir string) (*embedme.ConfigSettings, error) {
+	fs := afero.NewOsFs()
+	path := cmd.String(configFlag.Name)
+	profile := cmd.String(profileFlag.Name)
+	var err error
+	if path == "" {
+		if path, err = embedme.FindConfig(fs, workingDir); err != nil {
+			return nil, err
+		}
+	} else if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		if profile != "" {
+			return nil, fmt.Errorf("no configuration file for profile %q found in %s", profile, workingDir)
+		}
+		return nil, nil
+	}
+	configFile, err := embedme.LoadConfig(fs, path)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := configFile.Settings(profile)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// newOptions creates the options and the source finder
+//
+// The flags override the settings of the configuration file.
+func newOptions(cmd *cli.Command, config config) (embedme.Options, embedme.SourceFinder, error) {
+	options := embedme.Options{
+		StripEmbedComment: cmd.Bool(stripEmbedCommentFlag.Name),
+		Stdout:            config.Stdout,
+		Verify:            config.Verify,
+		DryRun:            config.DryRun,
+		WorkingDir:        config.WorkingDir,
+		Base:              config.WorkingDir,
+	}
+
+	finder := embedme.NewSourceFinder()
+	finder.WorkingDir = config.WorkingDir
+	finder.Glob = config.Glob
+
+	if config.Settings != nil {
+		if err := config.Settings.Apply(&options, &finder); err != nil {
+			return options, finder, err
+		}
+	}
+	applyFlags(cmd, config, &options, &finder)
+	if finder.NoIgnore {
+		return options, finder, nil
+	}
+	if excludesFile := embedme.GlobalExcludesFile(config.WorkingDir); excludesFile != "" {
+		finder.ExcludeFiles = []string{excludesFile}
+	}
+	return options, finder, nil
+}
+
+// applyFlags overrides the options and the source finder with the flags that are set
+func applyFlags(cmd *cli.Command, config config, options *embedme.Options, finder *embedme.SourceFinder) {
+	if config.Base != "" {
+		options.Base = config.Base
+	}
+	if format := cmd.String(formatFlag.Name); format != "" {
+		options.Format = format
+	}
+	if cmd.IsSet(stripCalloutsFlag.Name) {
+		options.StripCallouts = cmd.Bool(stripCalloutsFlag.Name)
+	}
+	options.Languages = append(options.Languages, config.Languages...)
+	if len(config.OnlyBlocks) > 0 {
+		options.OnlyBlocks = config.OnlyBlocks
+	}
+	if len(config.SkipBlocks) > 0 {
+		options.SkipBlocks = config.SkipBlocks
+	}
+	if cmd.IsSet(noIgnoreFlag.Name) {
+		finder.NoIgnore = config.NoIgnore
+	}
+}
+
 func sourcePatterns(cmd *cli.Command) []string {
 	allFlags := make(map[string]bool)
 	for _, flag := range cmd.Flags {
@@ -123,13 +233,9 @@ func run(ctx context.Context, cmd *cli.Command) error {
 
 	embedme.Magenta(log.Writer(), "embedme v%s\n", versionString())
 
-	options := embedme.Options{
-		StripEmbedComment: cmd.Bool(stripEmbedCommentFlag.Name),
-		Stdout:            config.Stdout,
-		Verify:            config.Verify,
-		DryRun:            config.DryRun,
-		WorkingDir:        config.WorkingDir,
-		Base:              config.Base,
+	options, finder, err := newOptions(cmd, config)
+	if err != nil {
+		return err
 	}
 
 	embedder, err := embedme.NewEmbedder(options)
@@ -137,20 +243,6 @@ func run(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("failed to create new embedder: %v", err)
 	}
 
-	ignoreFiles, err := embedme.GlobFiles(
-		embedder.FS,
-		options.WorkingDir,
-		".embedmeignore", ".gitignore",
-	)
-	if err != nil {
-		return fmt.Errorf("failed to find ignore files: %v", err)
-	}
-	finder := embedme.SourceFinder{
-		WorkingDir:  options.WorkingDir,
-		Glob:        config.Glob,
-		IgnoreFiles: ignoreFiles,
-	}
-
 	srcPatterns := sourcePatterns(cmd)
 	sources, err := finder.FindSources(embedder.FS, srcPatterns...)
 	if err != nil {
@@ -191,8 +283,8 @@ file(s) will be overwritten and the comment source is lost`)
 	return nil
 }
 
-func main() {
-	app := &cli.Command{
+func newApp() *cli.Command {
+	return &cli.Command{
 		Name:        "embedme",
 		Description: "utility for embedding code snippets into markdown documents",
 		Version:     versionString(),
@@ -204,13 +296,30 @@ func main() {
 			&cwdFlag,
 			&sourceBaseFlag,
 			&globFlag,
+			&noIgnoreFlag,
 			&silentFlag,
 			&stdoutFlag,
 			&outputFlag,
 			&stripEmbedCommentFlag,
+			&stripCalloutsFlag,
+			&formatFlag,
+			&languagesFlag,
+			&onlyBlockFlag,
+			&skipBlockFlag,
+			&configFlag,
+			&profileFlag,
+		},
+		// block selectors are regular expressions, which can contain commas
+		DisableSliceFlagSeparator: true,
+		Commands: []*cli.Command{
+			&addCommand,
 		},
 		Action: run,
 	}
+}
+
+func main() {
+	app := newApp()
 	err := app.Run(context.Background(), os.Args)
 	if err != nil {
 		embedme.Error(log.Writer(), "error: %v\n", err)
@@ -6,10 +6,10 @@ require (
 	github.com/fatih/color v1.16.0
 	github.com/google/go-cmp v0.6.0
 	github.com/k0kubun/pp/v3 v3.2.0
-	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/spf13/afero v1.11.0
 	github.com/urfave/cli/v3 v3.0.0-alpha9
 	golang.org/x/exp v0.0.0-20240416160154-fe59bbe5cc7f
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
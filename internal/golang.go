@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches the output comment of a Go example (see go/doc)
+	goExampleOutputRegex = regexp.MustCompile(`(?i)^[[:space:]]*(unordered )?output:`)
+)
+
+// FormatGoNode formats an AST node using gofmt style
+func FormatGoNode(fset *token.FileSet, node interface{}) ([]string, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		return nil, err
+	}
+	return strings.Split(buf.String(), "\n"), nil
+}
+
+// GoExampleBody formats the body of a Go example function
+//
+// The surrounding braces and the output comment are removed
+// and the body is unindented.
+func GoExampleBody(
+	fset *token.FileSet,
+	body *ast.BlockStmt,
+	comments []*ast.CommentGroup,
+) ([]string, error) {
+	var bodyComments []*ast.CommentGroup
+	for _, comment := range comments {
+		if comment.Pos() < body.Lbrace || comment.End() > body.Rbrace {
+			continue
+		}
+		bodyComments = append(bodyComments, comment)
+	}
+	if n := len(bodyComments); n > 0 {
+		if goExampleOutputRegex.MatchString(bodyComments[n-1].Text()) {
+			bodyComments = bodyComments[:n-1]
+		}
+	}
+
+	lines, err := FormatGoNode(fset, &printer.CommentedNode{
+		Node:     body,
+		Comments: bodyComments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// remove the braces
+	if len(lines) >= 2 {
+		lines = lines[1 : len(lines)-1]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	minIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, "\t"))
+		if minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+	for i, line := range lines {
+		if minIndent > 0 && len(line) >= minIndent {
+			lines[i] = line[minIndent:]
+		}
+	}
+	return lines, nil
+}
+
+// GoExampleOutput splits the expected output of a Go example into lines
+func GoExampleOutput(output string) []string {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
@@ -1,23 +1,23 @@
 package internal
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 var (
-	// Detects CRLF line endings
-	crlfRe = regexp.MustCompile("\r\n")
-	// Detects LF line endings
-	lfRe = regexp.MustCompile("\n")
+	crlf = []byte("\r\n")
+	lf   = []byte("\n")
 )
 
 // DetectNewline ...
 func DetectNewline(input []byte) string {
-	crlfs := crlfRe.FindAllIndex(input, -1)
-	lfs := lfRe.FindAllIndex(input, -1)
-	if len(crlfs) > len(lfs) {
+	crlfs := bytes.Count(input, crlf)
+	lfs := bytes.Count(input, lf)
+	if crlfs > lfs {
 		return "\r\n"
 	}
 	return "\n"
@@ -57,9 +57,94 @@ func Lines(source string) []string {
 	return strings.Split(source, newline)
 }
 
-// LineNumber ...
+// LineNumber returns the 1-based line number of a byte offset
+//
+// LineNumber splits the source before the offset for every call.
+// Use a LineIndex when looking up many offsets of the same source.
 func LineNumber(source string, pos int) int {
 	before := source[0:pos]
 	lines := Lines(before)
 	return len(lines)
 }
+
+// Dedent removes the common leading whitespace of all non-empty lines
+func Dedent(lines []string) []string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix = indent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		dedented[i] = strings.TrimPrefix(line, prefix)
+	}
+	return dedented
+}
+
+// TrimEmptyLines removes leading and trailing empty lines
+func TrimEmptyLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// IndentWidth returns the width of the leading whitespace of a line
+//
+// Tabs count as a single character.
+func IndentWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// LineIndex maps byte offsets of a source to line numbers
+//
+// The index is built in a single pass over the source and
+// each lookup is a binary search over the line start offsets.
+type LineIndex struct {
+	starts []int
+}
+
+// NewLineIndex builds the line index of a source
+func NewLineIndex(source string) LineIndex {
+	starts := []int{0}
+	for offset := 0; ; {
+		next := strings.IndexByte(source[offset:], '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
+		starts = append(starts, offset)
+	}
+	return LineIndex{starts: starts}
+}
+
+// Line returns the 1-based line number of a byte offset
+func (idx LineIndex) Line(offset int) int {
+	return sort.Search(len(idx.starts), func(i int) bool {
+		return idx.starts[i] > offset
+	})
+}
+
+// Offset returns the byte offset of the start of a 1-based line number
+func (idx LineIndex) Offset(line int) int {
+	return idx.starts[Max(0, Min(line, len(idx.starts))-1)]
+}
+
+// Count returns the number of lines
+func (idx LineIndex) Count() int {
+	return len(idx.starts)
+}
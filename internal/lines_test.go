@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func syntheticSource(lines int) string {
+	var source strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&source, "line %d of a synthetic document\n", i)
+	}
+	return source.String()
+}
+
+func TestLineIndex(t *testing.T) {
+	for _, source := range []string{"", "\n", "a", "a\nb", "a\nb\n", "\n\na\r\nb\n", syntheticSource(100)} {
+		idx := NewLineIndex(source)
+		for offset := 0; offset <= len(source); offset++ {
+			expected := LineNumber(source, offset)
+			if line := idx.Line(offset); line != expected {
+				t.Fatalf("%q: expected offset %d in line %d but got %d", source, offset, expected, line)
+			}
+			if start := idx.Offset(expected); start > offset || strings.Contains(source[start:offset], "\n") {
+				t.Fatalf("%q: line %d starts at invalid offset %d", source, expected, start)
+			}
+		}
+	}
+}
+
+// benchmarkLineLookups looks up the line of every 100th line of a large document
+func benchmarkLineLookups(b *testing.B, lookup func(source string) func(offset int) int) {
+	source := syntheticSource(20000)
+	var offsets []int
+	for offset := 0; offset < len(source); offset += 100 * 32 {
+		offsets = append(offsets, offset)
+	}
+	b.SetBytes(int64(len(source)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		line := lookup(source)
+		for _, offset := range offsets {
+			line(offset)
+		}
+	}
+}
+
+func BenchmarkLineNumber(b *testing.B) {
+	benchmarkLineLookups(b, func(source string) func(int) int {
+		return func(offset int) int {
+			return LineNumber(source, offset)
+		}
+	})
+}
+
+func BenchmarkLineIndex(b *testing.B) {
+	benchmarkLineLookups(b, func(source string) func(int) int {
+		return NewLineIndex(source).Line
+	})
+}
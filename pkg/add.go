@@ -0,0 +1,148 @@
+package embedme
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+)
+
+var (
+	// Matches an ATX heading (e.g. ## Usage)
+	atxHeadingRegex = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	// Matches the path of an embed command
+	commandPathRegex = regexp.MustCompile(`^\s*([^\s#$]+)`)
+)
+
+// markdownHeading is an ATX heading of a markdown document
+type markdownHeading struct {
+	level int
+	text  string
+}
+
+// parseHeading parses an ATX heading, the level is zero if the heading has no level
+func parseHeading(line string, requireLevel bool) (markdownHeading, bool) {
+	if match := atxHeadingRegex.FindStringSubmatch(line); match != nil {
+		return markdownHeading{level: len(match[1]), text: strings.TrimSpace(match[2])}, true
+	}
+	if requireLevel {
+		return markdownHeading{}, false
+	}
+	return markdownHeading{text: strings.TrimSpace(line)}, true
+}
+
+// matches checks if a heading matches the heading that is searched for
+func (h markdownHeading) matches(search markdownHeading) bool {
+	return (search.level == 0 || h.level == search.level) && strings.EqualFold(h.text, search.text)
+}
+
+// AddSnippet adds an embed comment and an empty code block for a command
+// to the end of the section under a heading of a markdown document
+//
+// The heading is given with or without its level (e.g. "## Usage" or "Usage").
+// The code block uses the fence style of the document and the language of
+// the embedded file, spelled like in the other code blocks of the document.
+// The language is looked up in the languages (the known languages if nil).
+// Running the embedder fills the code block.
+func AddSnippet(source string, heading string, command string, languages *LanguageRegistry) (string, error) {
+	search, _ := parseHeading(heading, false)
+	blocks := ExtractCodeBlocks(source)
+	lines := sourceLines(source)
+
+	section, end := -1, len(lines)
+	for i, line := range lines {
+		if insideCodeBlock(blocks, line.start) {
+			continue
+		}
+		current, ok := parseHeading(line.text, true)
+		if !ok {
+			continue
+		}
+		if section < 0 && current.matches(search) {
+			search.level = current.level
+			section = i
+		} else if section >= 0 && current.level <= search.level {
+			end = i
+			break
+		}
+	}
+	if section < 0 {
+		return "", fmt.Errorf("heading %q not found", heading)
+	}
+
+	// insert after the last line of the section that is not blank
+	last := end - 1
+	for last > section && lines[last].blank() {
+		last--
+	}
+	at := lines[last].end
+
+	newline := internal.DetectNewline([]byte(source))
+	indent := sectionIndent(blocks, lines[section].start, at)
+	fence := documentFence(blocks)
+	snippet := newline +
+		indent + "<!-- embedme " + strings.TrimSpace(command) + " -->" + newline +
+		indent + fence + string(documentLanguage(registryOrDefault(languages), blocks, command)) + newline +
+		indent + fence + newline
+	if !strings.HasSuffix(source[:at], "\n") {
+		snippet = newline + snippet
+	}
+	return source[:at] + snippet + source[at:], nil
+}
+
+// insideCodeBlock checks if an offset is inside of a fenced code block
+func insideCodeBlock(blocks []CodeBlock, offset int) bool {
+	for _, block := range blocks {
+		if block.FenceStart <= offset && offset <= block.End {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionIndent returns the indentation of the last code block of a section
+//
+// Code blocks in block quotes are not considered.
+func sectionIndent(blocks []CodeBlock, start int, end int) string {
+	indent := ""
+	for _, block := range blocks {
+		if block.FenceStart >= start && block.FenceStart < end && strings.TrimSpace(block.Indent) == "" {
+			indent = block.Indent
+		}
+	}
+	return indent
+}
+
+// documentFence returns the most common fence of a document
+func documentFence(blocks []CodeBlock) string {
+	fence, count := "```", 0
+	counts := make(map[string]int)
+	for _, block := range blocks {
+		counts[block.Fence]++
+		if counts[block.Fence] > count {
+			fence, count = block.Fence, counts[block.Fence]
+		}
+	}
+	return fence
+}
+
+// documentLanguage returns the language of the file of a command
+//
+// The language is spelled like in the code blocks of the document (e.g. golang or go).
+func documentLanguage(languages *LanguageRegistry, blocks []CodeBlock, command string) LanguageID {
+	match := commandPathRegex.FindStringSubmatch(command)
+	if match == nil {
+		return ""
+	}
+	lang, ok := languages.Lookup(languages.languageForPath(match[1]))
+	if !ok {
+		return ""
+	}
+	for _, block := range blocks {
+		if other, ok := languages.Lookup(block.Language); ok && other.Name == lang.Name {
+			return block.Language
+		}
+	}
+	return lang.ID()
+}
@@ -0,0 +1,159 @@
+package embedme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAddSnippet(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		source      string
+		heading     string
+		command     string
+		expected    string
+	}{
+		{
+			description: "end of section with fence and language of the document",
+			source: `
+# Demo
+
+## Install
+
+~~~golang
+// main.go
+~~~
+
+## Usage
+
+Some text.
+
+
+## License
+			`,
+			heading: "## Usage",
+			command: "pkg/options.go#type:Options",
+			expected: `
+# Demo
+
+## Install
+
+~~~golang
+// main.go
+~~~
+
+## Usage
+
+Some text.
+
+<!-- embedme pkg/options.go#type:Options -->
+~~~golang
+~~~
+
+
+## License
+			`,
+		},
+		{
+			description: "heading without level and headings in code blocks",
+			source: `
+# Demo
+
+FENCEmarkdown
+## Usage
+FENCE
+
+## Usage
+
+### Details
+
+Nested section.
+
+# Next
+			`,
+			heading: "usage",
+			command: "values.yml",
+			expected: `
+# Demo
+
+FENCEmarkdown
+## Usage
+FENCE
+
+## Usage
+
+### Details
+
+Nested section.
+
+<!-- embedme values.yml -->
+FENCEyaml
+FENCE
+
+# Next
+			`,
+		},
+		{
+			description: "indentation of the code blocks in the section",
+			source: `
+## Steps
+
+1. Build
+
+   FENCEsh
+   make
+   FENCE
+			`,
+			heading: "Steps",
+			command: "$ ls",
+			expected: `
+## Steps
+
+1. Build
+
+   FENCEsh
+   make
+   FENCE
+
+   <!-- embedme $ ls -->
+   FENCE
+   FENCE
+			`,
+		},
+	} {
+		source := strings.ReplaceAll(strings.TrimSpace(c.source), "FENCE", "```") + "\n"
+		expected := strings.ReplaceAll(strings.TrimSpace(c.expected), "FENCE", "```") + "\n"
+		added, err := AddSnippet(source, c.heading, c.command, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to add snippet: %v", c.description, err)
+		}
+		if diff := cmp.Diff(added, expected); diff != "" {
+			t.Errorf("%s: unexpected document: %s", c.description, diff)
+		}
+	}
+
+	// the snippet is added after the last line without line ending
+	added, err := AddSnippet("## Usage", "## Usage", "main.go", nil)
+	if err != nil {
+		t.Fatalf("failed to add snippet: %v", err)
+	}
+	if expected := "## Usage\n\n<!-- embedme main.go -->\n```go\n```\n"; added != expected {
+		t.Errorf("expected %q but got %q", expected, added)
+	}
+
+	if _, err := AddSnippet("# Usage\n", "## Usage", "main.go", nil); err == nil {
+		t.Errorf("expected missing heading to fail")
+	}
+
+	// the language of the file is looked up in the given languages
+	languages := Languages.With(Language{Name: "Just", Aliases: []LanguageID{"just"}, Filenames: []string{"Justfile"}})
+	added, err = AddSnippet("## Usage\n", "## Usage", "Justfile", languages)
+	if err != nil {
+		t.Fatalf("failed to add snippet: %v", err)
+	}
+	if expected := "## Usage\n\n<!-- embedme Justfile -->\n```just\n```\n"; added != expected {
+		t.Errorf("expected %q but got %q", expected, added)
+	}
+}
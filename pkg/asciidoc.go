@@ -0,0 +1,231 @@
+package embedme
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches the delimiter of a listing, literal, comment or passthrough block
+	asciidocDelimiterRegex = regexp.MustCompile(`^(-{4,}|\.{4,}|/{4,}|\+{4,})$`)
+	// Matches a block attribute list
+	asciidocAttributesRegex = regexp.MustCompile(`^\[([^\]]*)\]$`)
+	// Matches a block title
+	asciidocTitleRegex = regexp.MustCompile(`^\.[^.\s]`)
+	// Matches the source-language document attribute
+	asciidocSourceLanguageRegex = regexp.MustCompile(`^:source-language:\s*(\S*)$`)
+	// Matches an embed comment
+	asciidocEmbedCommentRegex = regexp.MustCompile(`^//\s*embedme\s+(.+?)$`)
+	// Matches an ignore next comment
+	asciidocIgnoreRegex = regexp.MustCompile(`^//\s*embedme[ -]ignore-(next|start|end|file)$`)
+	// Matches the callouts at the end of a line, optionally preceded by a line comment
+	asciidocCalloutsRegex = regexp.MustCompile(
+		`[ \t]*((//|#|;;|--)[ \t]*)?(<\d+>|<!--\d+-->)([ \t]*(<\d+>|<!--\d+-->))*[ \t]*$`,
+	)
+)
+
+// ExtractAsciiDocBlocks extracts the source blocks of an AsciiDoc document
+//
+// Source blocks are listing blocks delimited by ---- with a source style,
+// e.g. [source,go]. The language defaults to the source-language document attribute.
+// Literal, comment and passthrough blocks are skipped.
+//
+// An embed comment (// embedme path) or ignore next comment (// embedme-ignore-next)
+// applies to the next source block. Without an embed comment, the command can
+// also be given by the embed attribute, e.g. [source,go,embed="main.go"].
+func ExtractAsciiDocBlocks(source string) []CodeBlock {
+	scanner := asciidocScanner{source: source, lines: sourceLines(source)}
+	scanner.scan()
+	return scanner.blocks
+}
+
+// AsciiDocFormat is the format of AsciiDoc documents
+type AsciiDocFormat struct{}
+
+// Name ...
+func (AsciiDocFormat) Name() string {
+	return "asciidoc"
+}
+
+// ParseDirective parses // embedme path and // embedme-ignore-... comments
+func (AsciiDocFormat) ParseDirective(line string) (Directive, bool) {
+	return parseDirective(strings.TrimRight(line, " \t"), asciidocIgnoreRegex, asciidocEmbedCommentRegex)
+}
+
+// CodeBlocks ...
+func (AsciiDocFormat) CodeBlocks(source string) []CodeBlock {
+	return ExtractAsciiDocBlocks(source)
+}
+
+// Replace lengthens the delimiters if the code contains a delimiter line
+func (AsciiDocFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	// blocks are closed by a line that matches the opening delimiter exactly
+	delimiter := block.Fence
+	for containsLine(code, delimiter) {
+		delimiter += delimiter[:1]
+	}
+	if delimiter == block.Fence {
+		return block.Start, block.End, code
+	}
+	opening := delimiter + source[block.FenceStart+len(block.Fence):block.Start]
+	return block.FenceStart, block.End + len(block.ClosingFence), opening + code + delimiter
+}
+
+// RendersCallouts ...
+func (AsciiDocFormat) RendersCallouts() bool {
+	return true
+}
+
+// containsLine checks if code contains a line (without trailing whitespace)
+func containsLine(code string, line string) bool {
+	for _, l := range strings.Split(code, "\n") {
+		if strings.TrimRight(l, " \t\r") == line {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCallouts removes AsciiDoc callouts (e.g. // <1>) from the end of lines
+func stripCallouts(lines []string) []string {
+	stripped := make([]string, len(lines))
+	for i, line := range lines {
+		stripped[i] = asciidocCalloutsRegex.ReplaceAllString(line, "")
+	}
+	return stripped
+}
+
+// asciidocScanner scans an AsciiDoc document line by line
+type asciidocScanner struct {
+	source string
+	lines  []sourceLine
+	// language is the default source language
+	language   LanguageID
+	attributes *asciidocAttributes
+	directive  Directive
+	blocks     []CodeBlock
+}
+
+func (s *asciidocScanner) scan() {
+	for i := 0; i < len(s.lines); i++ {
+		text := strings.TrimRight(s.lines[i].text, " \t")
+		switch {
+		case text == "":
+		case s.directive.update(AsciiDocFormat{}, text):
+		case asciidocDelimiterRegex.MatchString(text):
+			i = s.delimitedBlock(i, text)
+		case strings.HasPrefix(text, "//"):
+			// line comment
+		case asciidocSourceLanguageRegex.MatchString(text):
+			s.language = sourceLanguage(asciidocSourceLanguageRegex.FindStringSubmatch(text)[1])
+		case asciidocAttributesRegex.MatchString(text):
+			s.attributes = parseAsciidocAttributes(asciidocAttributesRegex.FindStringSubmatch(text)[1])
+		case asciidocTitleRegex.MatchString(text):
+		default:
+			s.attributes = nil
+			s.directive = Directive{}
+		}
+	}
+}
+
+// delimitedBlock scans the delimited block that starts in line i and returns its last line
+func (s *asciidocScanner) delimitedBlock(i int, delimiter string) int {
+	attributes, directive := s.attributes, s.directive
+	s.attributes, s.directive = nil, Directive{}
+
+	end := i + 1
+	for end < len(s.lines) && strings.TrimRight(s.lines[end].text, " \t") != delimiter {
+		end++
+	}
+	if end == len(s.lines) || delimiter[0] != '-' {
+		// unclosed or not a listing block
+		return end
+	}
+
+	block := CodeBlock{
+		FenceStart:   s.lines[i].start,
+		Fence:        delimiter,
+		ClosingFence: delimiter,
+		Start:        s.lines[i].end,
+		End:          s.lines[end].start,
+		StartLine:    i + 2,
+		EndLine:      end + 1,
+		Code:         s.source[s.lines[i].end:s.lines[end].start],
+		EmbedComment: directive.Command,
+		Ignore:       directive.IgnoreNext,
+	}
+	if attributes != nil {
+		block.Language = attributes.language(s.language)
+		block.Info = attributes.list
+		block.Attributes = attributes.named
+	}
+	if block.EmbedComment == "" {
+		block.EmbedComment = infoStringDirective(block.Attributes)
+	}
+	s.blocks = append(s.blocks, block)
+	return end
+}
+
+// asciidocAttributes is a block attribute list
+type asciidocAttributes struct {
+	list       string
+	positional []string
+	named      map[string]string
+}
+
+func parseAsciidocAttributes(list string) *asciidocAttributes {
+	attributes := &asciidocAttributes{list: list}
+	for _, attribute := range splitAsciidocAttributes(list) {
+		if parts := strings.SplitN(attribute, "=", 2); len(parts) == 2 {
+			attributes.named = setAttribute(attributes.named, strings.TrimSpace(parts[0]), unquote(parts[1]))
+		} else {
+			attributes.positional = append(attributes.positional, attribute)
+		}
+	}
+	return attributes
+}
+
+// language returns the language of a source block
+func (a *asciidocAttributes) language(fallback LanguageID) LanguageID {
+	if len(a.positional) == 0 {
+		return ""
+	}
+	// the style can have an id, roles and options (e.g. source#id.role%linenums)
+	style := a.positional[0]
+	if end := strings.IndexAny(style, "#.%"); end >= 0 {
+		style = style[:end]
+	}
+	if style != "source" && !(style == "" && len(a.positional) > 1) {
+		return ""
+	}
+	if language, ok := a.named["language"]; ok {
+		return sourceLanguage(language)
+	}
+	if len(a.positional) > 1 && a.positional[1] != "" {
+		return sourceLanguage(a.positional[1])
+	}
+	return fallback
+}
+
+// splitAsciidocAttributes splits an attribute list at commas outside of quotes
+func splitAsciidocAttributes(list string) []string {
+	var attributes []string
+	var attribute strings.Builder
+	var quote rune
+	for _, c := range list {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			attributes = append(attributes, strings.TrimSpace(attribute.String()))
+			attribute.Reset()
+			continue
+		}
+		attribute.WriteRune(c)
+	}
+	return append(attributes, strings.TrimSpace(attribute.String()))
+}
@@ -0,0 +1,198 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestExtractAsciiDocBlocks(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		source      string
+		expected    []documentBlock
+	}{
+		{
+			description: "source block",
+			source:      "[source,go]\n----\nfunc main() {}\n----\n",
+			expected:    []documentBlock{{"go", "", false, "func main() {}\n"}},
+		},
+		{
+			description: "embed comment, title and source language mapping",
+			source:      "// embedme main.rb#L1-10\n\n.Greeting\n[source, ruby, linenums]\n----\nputs 1\n----\n",
+			expected:    []documentBlock{{"rb", "main.rb#L1-10", false, "puts 1\n"}},
+		},
+		{
+			description: "ignore next comment",
+			source:      "// embedme-ignore-next\n[source,go]\n----\n----\n",
+			expected:    []documentBlock{{"go", "", true, ""}},
+		},
+		{
+			description: "embed attribute and default source language",
+			source:      ":source-language: python\n\n[source%linenums,embed=\"greet.py\"]\n------\n------\n",
+			expected:    []documentBlock{{"python", "greet.py", false, ""}},
+		},
+		{
+			description: "embed comment only applies to the next block",
+			source:      "// embedme main.go\n\nText\n\n[,go]\n----\na\n----\n",
+			expected:    []documentBlock{{"go", "", false, "a\n"}},
+		},
+		{
+			description: "listing block without source style",
+			source:      "----\na\n-----\n----\n",
+			expected:    []documentBlock{{"", "", false, "a\n-----\n"}},
+		},
+		{
+			description: "literal, comment and unclosed blocks are skipped",
+			source:      "....\n----\n....\n\n////\n----\n////\n\n[source,go]\n----\na\n",
+			expected:    nil,
+		},
+	} {
+		var blocks []documentBlock
+		for _, block := range ExtractAsciiDocBlocks(c.source) {
+			blocks = append(blocks, documentBlock{
+				Language:     block.Language,
+				EmbedComment: block.EmbedComment,
+				Ignore:       block.Ignore,
+				Content:      block.Content(),
+			})
+		}
+		if diff := cmp.Diff(blocks, c.expected); diff != "" {
+			t.Log(c.source)
+			t.Errorf("%s: unexpected code blocks: %s", c.description, diff)
+		}
+	}
+}
+
+func TestStripCallouts(t *testing.T) {
+	lines := []string{
+		`fmt.Println("hello") // <1>`,
+		`name = "embedme" # <2> <3>`,
+		`<key>value</key> <!--4-->`,
+		`x := a <1`,
+		`// see <1>`,
+		`  <1>`,
+	}
+	expected := []string{
+		`fmt.Println("hello")`,
+		`name = "embedme"`,
+		`<key>value</key>`,
+		`x := a <1`,
+		`// see`,
+		``,
+	}
+	if diff := cmp.Diff(stripCallouts(lines), expected); diff != "" {
+		t.Fatalf("unexpected lines: %s", diff)
+	}
+}
+
+func TestEmbedAsciiDoc(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/main.go", []byte(strings.TrimSpace(`
+package main
+
+func main() {
+	println("----") // <1>
+}
+	`)), 0644)
+	afero.WriteFile(fs, "/work/dir/separator.txt", []byte("----\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	options.StripCallouts = true
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+= Usage
+
+// embedme main.go
+[source,go]
+----
+----
+<1> Prints a separator
+
+[source,text,embed=separator.txt]
+----
+----
+	`) + "\n"
+
+	expected := strings.TrimSpace(`
+= Usage
+
+// embedme main.go
+[source,go]
+----
+package main
+
+func main() {
+	println("----") // <1>
+}
+----
+<1> Prints a separator
+
+[source,text,embed=separator.txt]
+-----
+----
+-----
+	`) + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.adoc"), "readme.adoc")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	// embedding again must not change the document
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "readme.adoc"), "readme.adoc")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+
+	// callouts are stripped from other documents
+	markdown, err := embedder.Embed(
+		[]byte("<!-- embedme main.go -->\n```go\n```\n"),
+		filepath.Join(workingDir, "readme.md"),
+		"readme.md",
+	)
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if strings.Contains(markdown, "<1>") {
+		t.Fatalf("expected callouts to be stripped:\n%s", markdown)
+	}
+}
+
+// plainAsciiDocFormat is an AsciiDoc format that does not render callouts
+type plainAsciiDocFormat struct {
+	AsciiDocFormat
+}
+
+func (plainAsciiDocFormat) RendersCallouts() bool {
+	return false
+}
+
+func TestRendersCallouts(t *testing.T) {
+	if !rendersCallouts(AsciiDocFormat{}) {
+		t.Errorf("expected AsciiDoc to render callouts")
+	}
+	if rendersCallouts(plainAsciiDocFormat{}) {
+		t.Errorf("expected a format that returns false to not render callouts")
+	}
+	if rendersCallouts(MarkdownFormat{}) {
+		t.Errorf("expected markdown to not render callouts")
+	}
+}
@@ -0,0 +1,193 @@
+package embedme
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Matches a line (e.g. 42) or a range of lines (e.g. 10-20)
+	blockLinesRegex = regexp.MustCompile(`^\s*(\d+)(?:\s*-\s*(\d+))?\s*$`)
+)
+
+// BlockSelector selects code blocks by their lines or by their embed command
+type BlockSelector struct {
+	// StartLine is the first selected line
+	StartLine int
+	// EndLine is the last selected line
+	EndLine int
+	// Command matches the embed command of a code block (e.g. \.go$)
+	Command *regexp.Regexp
+}
+
+// ParseBlockSelector parses a line (e.g. 42), a range of lines (e.g. 10-20)
+// or a regular expression that matches the embed command of a code block
+func ParseBlockSelector(selector string) (BlockSelector, error) {
+	if match := blockLinesRegex.FindStringSubmatch(selector); match != nil {
+		start, _ := strconv.Atoi(match[1])
+		end := start
+		if match[2] != "" {
+			end, _ = strconv.Atoi(match[2])
+		}
+		if end < start {
+			return BlockSelector{}, fmt.Errorf("invalid block selector %q: line range ends before it starts", selector)
+		}
+		return BlockSelector{StartLine: start, EndLine: end}, nil
+	}
+	command, err := regexp.Compile(selector)
+	if err != nil {
+		return BlockSelector{}, fmt.Errorf("invalid block selector %q: %v", selector, err)
+	}
+	return BlockSelector{Command: command}, nil
+}
+
+// ParseBlockSelectors parses block selectors (see ParseBlockSelector)
+func ParseBlockSelectors(values []string) ([]BlockSelector, error) {
+	var selectors []BlockSelector
+	for _, value := range values {
+		selector, err := ParseBlockSelector(value)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// String ...
+func (s BlockSelector) String() string {
+	if s.Command != nil {
+		return s.Command.String()
+	}
+	if s.StartLine == s.EndLine {
+		return strconv.Itoa(s.StartLine)
+	}
+	return fmt.Sprintf("%d-%d", s.StartLine, s.EndLine)
+}
+
+// Matches checks if a code block with lines and an embed command is selected
+//
+// Line selectors select the code blocks with a selected line.
+func (s BlockSelector) Matches(startLine int, endLine int, command string) bool {
+	if s.Command != nil {
+		return s.Command.MatchString(command)
+	}
+	return startLine <= s.EndLine && s.StartLine <= endLine
+}
+
+// blockLines returns the first and last line of a code block including
+// its fences and the embed comments before it
+//
+// The lines are the lines of the document (see sourceLines).
+func blockLines(lines []sourceLine, format DocumentFormat, block *CodeBlock) (int, int) {
+	lineAt := func(offset int) int {
+		i := sort.Search(len(lines), func(i int) bool {
+			return offset < lines[i].end
+		})
+		if i == len(lines) {
+			return len(lines) - 1
+		}
+		return i
+	}
+	start := block.Start
+	if block.Fence != "" {
+		start = block.FenceStart
+	}
+	first, last := lineAt(start), lineAt(block.End)
+	for i := first - 1; i >= 0; i-- {
+		if _, ok := format.ParseDirective(lines[i].text); ok {
+			first = i
+		} else if !lines[i].blank() {
+			break
+		}
+	}
+	return first + 1, last + 1
+}
+
+// ignoreRegions returns the offsets of the regions between ignore start and
+// end comments of a document and if the document has an ignore file comment
+//
+// Comments inside of code blocks are not considered and
+// regions without an end comment end with the document.
+func ignoreRegions(lines []sourceLine, format DocumentFormat, blocks []CodeBlock) ([][2]int, bool) {
+	var regions [][2]int
+	start := -1
+	// blocks are in order of their offsets
+	block := 0
+	for _, line := range lines {
+		for block < len(blocks) && blocks[block].End <= line.start {
+			block++
+		}
+		if block < len(blocks) && blocks[block].Start <= line.start {
+			continue
+		}
+		directive, ok := format.ParseDirective(line.text)
+		switch {
+		case !ok:
+		case directive.IgnoreFile:
+			return nil, true
+		case directive.IgnoreStart && start < 0:
+			start = line.start
+		case directive.IgnoreEnd && start >= 0:
+			regions = append(regions, [2]int{start, line.end})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		regions = append(regions, [2]int{start, lines[len(lines)-1].end})
+	}
+	return regions, false
+}
+
+// skipReason returns why a code block is skipped or an empty string
+// if the code block is not in an ignore region and selected by the options
+func (e *Embedder) skipReason(
+	lines []sourceLine,
+	format DocumentFormat,
+	block *CodeBlock,
+	regions [][2]int,
+) string {
+	for _, region := range regions {
+		if region[0] <= block.Start && block.Start < region[1] {
+			return "Ignore region detected"
+		}
+	}
+	if len(e.Options.OnlyBlocks) == 0 && len(e.Options.SkipBlocks) == 0 {
+		return ""
+	}
+	startLine, endLine := blockLines(lines, format, block)
+	command := e.blockCommand(block)
+	if len(e.Options.OnlyBlocks) > 0 && !selected(e.Options.OnlyBlocks, startLine, endLine, command) {
+		return "Block not selected"
+	}
+	if selected(e.Options.SkipBlocks, startLine, endLine, command) {
+		return "Block skipped"
+	}
+	return ""
+}
+
+// blockCommand returns the embed command of a code block or the first comment of its code
+func (e *Embedder) blockCommand(block *CodeBlock) string {
+	if block.EmbedComment != "" || block.Language == "" {
+		return block.EmbedComment
+	}
+	lang, err := e.Options.language(block.Language)
+	if err != nil {
+		return ""
+	}
+	comment, _ := FirstComment(block.Content(), lang)
+	return strings.TrimSpace(comment)
+}
+
+// selected checks if one of the selectors matches a code block
+func selected(selectors []BlockSelector, startLine int, endLine int, command string) bool {
+	for _, selector := range selectors {
+		if selector.Matches(startLine, endLine, command) {
+			return true
+		}
+	}
+	return false
+}
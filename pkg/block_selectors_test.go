@@ -0,0 +1,179 @@
+package embedme
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func newSelectorTestEmbedder(options Options) Embedder {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+	afero.WriteFile(fs, "/work/dir/main.go", []byte("package main\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/config.yaml", []byte("debug: true\n"), 0644)
+
+	options.WorkingDir = workingDir
+	return Embedder{
+		Options: options,
+		FS:      fs,
+	}
+}
+
+func TestParseBlockSelector(t *testing.T) {
+	cases := []struct {
+		selector string
+		expected string
+		lines    [2]int
+	}{
+		{"42", "42", [2]int{42, 42}},
+		{"10-20", "10-20", [2]int{10, 20}},
+		{`\.go$`, `\.go$`, [2]int{}},
+		{"main.go#L{1,2}", "main.go#L{1,2}", [2]int{}},
+	}
+	for _, c := range cases {
+		selector, err := ParseBlockSelector(c.selector)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", c.selector, err)
+		}
+		if selector.String() != c.expected {
+			t.Errorf("expected %q but got %q", c.expected, selector.String())
+		}
+		if lines := [2]int{selector.StartLine, selector.EndLine}; lines != c.lines {
+			t.Errorf("expected lines %v of %q but got %v", c.lines, c.selector, lines)
+		}
+	}
+	for _, invalid := range []string{"20-10", "main.go("} {
+		if _, err := ParseBlockSelector(invalid); err == nil {
+			t.Errorf("expected selector %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestEmbedIgnoreRegions(t *testing.T) {
+	embedder := newSelectorTestEmbedder(NewDefaultOptions())
+
+	fence := "```"
+	readme := "" +
+		"<!-- embedme-ignore-start -->\n" +
+		fence + "go\n" +
+		"// main.go\n" +
+		fence + "\n\n" +
+		fence + "md\n" +
+		"<!-- embedme-ignore-end -->\n" +
+		fence + "\n\n" +
+		"<!-- embedme config.yaml -->\n" +
+		fence + "yaml\n" +
+		fence + "\n" +
+		"<!-- embedme-ignore-end -->\n\n" +
+		"<!-- embedme main.go -->\n" +
+		fence + "go\n" +
+		fence + "\n"
+	expected := "" +
+		"<!-- embedme-ignore-start -->\n" +
+		fence + "go\n" +
+		"// main.go\n" +
+		fence + "\n\n" +
+		fence + "md\n" +
+		"<!-- embedme-ignore-end -->\n" +
+		fence + "\n\n" +
+		"<!-- embedme config.yaml -->\n" +
+		fence + "yaml\n" +
+		fence + "\n" +
+		"<!-- embedme-ignore-end -->\n\n" +
+		"<!-- embedme main.go -->\n" +
+		fence + "go\n" +
+		"package main\n" +
+		fence + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join("/work/dir", "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded markdown: %s", diff)
+	}
+}
+
+func TestEmbedIgnoreFile(t *testing.T) {
+	embedder := newSelectorTestEmbedder(NewDefaultOptions())
+
+	fence := "```"
+	readme := "" +
+		"<!-- embedme main.go -->\n" +
+		fence + "go\n" +
+		fence + "\n\n" +
+		"<!-- embedme-ignore-file -->\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join("/work/dir", "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, readme); diff != "" {
+		t.Fatalf("expected ignored document to be unchanged: %s", diff)
+	}
+}
+
+func TestEmbedBlockSelectors(t *testing.T) {
+	fence := "```"
+	mainBlock := func(code string) string {
+		return "<!-- embedme main.go -->\n" + fence + "go\n" + code + fence + "\n\n"
+	}
+	configBlock := func(code string) string {
+		return "<!-- embedme config.yaml -->\n" + fence + "yaml\n" + code + fence + "\n\n"
+	}
+	commentBlock := func(code string) string {
+		return fence + "go\n// main.go\n" + code + fence + "\n\n"
+	}
+	quoteBlock := func(code string) string {
+		return "> " + fence + "go\n> // main.go\n" + code + "> " + fence + "\n"
+	}
+	readme := mainBlock("") + configBlock("") + commentBlock("") + quoteBlock("")
+
+	cases := []struct {
+		only     []string
+		skip     []string
+		expected string
+	}{
+		{
+			only:     []string{"5-6"},
+			expected: mainBlock("") + configBlock("debug: true\n") + commentBlock("") + quoteBlock(""),
+		},
+		{
+			only: []string{`^main\.go$`},
+			expected: mainBlock("package main\n") + configBlock("") +
+				commentBlock("\npackage main\n") + quoteBlock("> \n> package main\n"),
+		},
+		{
+			skip:     []string{"main"},
+			expected: mainBlock("") + configBlock("debug: true\n") + commentBlock("") + quoteBlock(""),
+		},
+		{
+			only: []string{"go"},
+			skip: []string{"1"},
+			expected: mainBlock("") + configBlock("") +
+				commentBlock("\npackage main\n") + quoteBlock("> \n> package main\n"),
+		},
+	}
+	for _, c := range cases {
+		options := NewDefaultOptions()
+		for _, only := range c.only {
+			selector, _ := ParseBlockSelector(only)
+			options.OnlyBlocks = append(options.OnlyBlocks, selector)
+		}
+		for _, skip := range c.skip {
+			selector, _ := ParseBlockSelector(skip)
+			options.SkipBlocks = append(options.SkipBlocks, selector)
+		}
+		embedder := newSelectorTestEmbedder(options)
+		embedded, err := embedder.Embed([]byte(readme), filepath.Join("/work/dir", "readme.md"), "readme.md")
+		if err != nil {
+			t.Fatalf("failed to embed: %v", err)
+		}
+		if diff := cmp.Diff(embedded, c.expected); diff != "" {
+			t.Errorf("unexpected embedded markdown for only %v and skip %v: %s", c.only, c.skip, diff)
+		}
+	}
+}
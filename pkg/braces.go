@@ -0,0 +1,271 @@
+package embedme
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+	"golang.org/x/exp/maps"
+)
+
+// BraceSymbolExtractor extracts symbols from languages that delimit blocks with braces
+//
+// The extractor finds the header of a symbol using a regular expression for its kind
+// and then scans to the matching closing brace, skipping strings and comments.
+// Symbols without a body end at the first semicolon or at the end of the header line.
+// Nested symbols are selected using dotted names, e.g. "fn:Client.greet".
+type BraceSymbolExtractor struct {
+	// Name of the language
+	Name string
+	// Kinds maps a symbol kind to a regular expression for its header line
+	//
+	// The expression must contain a single %s placeholder for the name of the symbol.
+	Kinds map[string]string
+	// Preamble matches lines before the header that belong to the symbol
+	// (e.g. doc comments, attributes or decorators)
+	Preamble *regexp.Regexp
+	// LineComment starts a comment that ends with the line
+	LineComment string
+	// BlockComment is the start and end of a block comment
+	BlockComment [2]string
+	// Quotes are the characters that delimit strings
+	Quotes string
+	// CharLiterals enables Rust style character literals, which must be
+	// distinguished from lifetimes
+	CharLiterals bool
+}
+
+var (
+	rustVisibility = `^\s*(pub(\([^)]*\))?\s+)?`
+	// RustSymbolExtractor extracts symbols from Rust source files
+	RustSymbolExtractor = BraceSymbolExtractor{
+		Name: "Rust",
+		Kinds: map[string]string{
+			"fn":     rustVisibility + `((const|async|unsafe|extern(\s+"[^"]*")?)\s+)*fn\s+%s\b`,
+			"struct": rustVisibility + `struct\s+%s\b`,
+			"enum":   rustVisibility + `enum\s+%s\b`,
+			"union":  rustVisibility + `union\s+%s\b`,
+			"trait":  rustVisibility + `(unsafe\s+)?trait\s+%s\b`,
+			"type":   rustVisibility + `type\s+%s\b`,
+			"mod":    rustVisibility + `mod\s+%s\b`,
+			"const":  rustVisibility + `const\s+%s\b`,
+			"static": rustVisibility + `static\s+(mut\s+)?%s\b`,
+			"impl":   `^\s*(unsafe\s+)?impl(<[^{]*?>)?\s+([^{]*?\s+for\s+)?([\w:]*::)?%s\b`,
+			"macro":  `^\s*macro_rules!\s*%s\b`,
+		},
+		Preamble:     regexp.MustCompile(`^\s*(//|/\*|\*|#\[)`),
+		LineComment:  "//",
+		BlockComment: [2]string{"/*", "*/"},
+		Quotes:       `"`,
+		CharLiterals: true,
+	}
+
+	typescriptModifiers = `^\s*(export\s+)?(default\s+)?(declare\s+)?`
+	// TypescriptSymbolExtractor extracts symbols from Typescript and Javascript source files
+	TypescriptSymbolExtractor = BraceSymbolExtractor{
+		Name: "Typescript",
+		Kinds: map[string]string{
+			"function":  typescriptModifiers + `(async\s+)?function\s*\*?\s*%s\b`,
+			"fn":        typescriptModifiers + `(async\s+)?function\s*\*?\s*%s\b`,
+			"class":     typescriptModifiers + `(abstract\s+)?class\s+%s\b`,
+			"interface": typescriptModifiers + `interface\s+%s\b`,
+			"type":      typescriptModifiers + `type\s+%s\b`,
+			"enum":      typescriptModifiers + `(const\s+)?enum\s+%s\b`,
+			"namespace": typescriptModifiers + `(namespace|module)\s+%s\b`,
+			"const":     typescriptModifiers + `(const|let|var)\s+%s\b`,
+			"method": `^\s*((public|private|protected|static|async|readonly|override|get|set)\s+)*` +
+				`\*?%s\s*(<[^>]*>)?\(`,
+		},
+		Preamble:     regexp.MustCompile(`^\s*(//|/\*|\*|@)`),
+		LineComment:  "//",
+		BlockComment: [2]string{"/*", "*/"},
+		Quotes:       "\"'`",
+	}
+
+	// ProtobufSymbolExtractor extracts symbols from protocol buffer files
+	ProtobufSymbolExtractor = BraceSymbolExtractor{
+		Name: "Protobuf",
+		Kinds: map[string]string{
+			"message": `^\s*message\s+%s\b`,
+			"enum":    `^\s*enum\s+%s\b`,
+			"service": `^\s*service\s+%s\b`,
+			"rpc":     `^\s*rpc\s+%s\s*\(`,
+			"oneof":   `^\s*oneof\s+%s\b`,
+		},
+		Preamble:     regexp.MustCompile(`^\s*(//|/\*|\*)`),
+		LineComment:  "//",
+		BlockComment: [2]string{"/*", "*/"},
+		Quotes:       `"'`,
+	}
+)
+
+// Extract ...
+func (e BraceSymbolExtractor) Extract(path string, content []byte, kind string, name string) ([]string, error) {
+	if _, ok := e.Kinds[kind]; !ok {
+		kinds := maps.Keys(e.Kinds)
+		sort.Strings(kinds)
+		return nil, fmt.Errorf(
+			"unsupported %s selector %q (must be one of %v)",
+			e.Name, kind+":"+name, kinds,
+		)
+	}
+	lines := internal.Lines(string(content))
+	start, end := 0, len(lines)
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		var headers []string
+		if i == len(parts)-1 {
+			headers = []string{e.Kinds[kind]}
+		} else {
+			// enclosing symbols can be of any kind
+			headers = maps.Values(e.Kinds)
+		}
+		var ok bool
+		start, end, ok = e.findBlock(lines, start, end, headers, part)
+		if !ok {
+			return nil, symbolNotFound(path, kind, name)
+		}
+	}
+	return internal.Dedent(lines[start:end]), nil
+}
+
+// findBlock finds the lines of the first symbol in lines[from:to] that matches one of the headers
+func (e BraceSymbolExtractor) findBlock(
+	lines []string,
+	from int,
+	to int,
+	headers []string,
+	name string,
+) (int, int, bool) {
+	var patterns []*regexp.Regexp
+	for _, header := range headers {
+		patterns = append(patterns, symbolHeaderRegex(header, name))
+	}
+	for i := from; i < to; i++ {
+		for _, pattern := range patterns {
+			if !pattern.MatchString(lines[i]) {
+				continue
+			}
+			start := i
+			for start > from && e.Preamble != nil && e.Preamble.MatchString(lines[start-1]) {
+				start--
+			}
+			return start, e.blockEnd(lines, i, to), true
+		}
+	}
+	return 0, 0, false
+}
+
+// blockEnd finds the end of the symbol starting in the header line
+func (e BraceSymbolExtractor) blockEnd(lines []string, header int, to int) int {
+	scanner := braceScanner{extractor: &e}
+	opened := false
+	for i := header; i < to; i++ {
+		for _, c := range scanner.scan(lines[i]) {
+			switch c {
+			case '(', '[':
+				scanner.parens++
+			case ')', ']':
+				scanner.parens--
+			case '{':
+				opened = true
+				scanner.braces++
+			case '}':
+				scanner.braces--
+				if opened && scanner.braces == 0 {
+					return i + 1
+				}
+			case ';':
+				if !opened && scanner.parens == 0 {
+					return i + 1
+				}
+			}
+		}
+		if !opened && scanner.parens == 0 && !e.continues(lines, i, header, to) {
+			return i + 1
+		}
+	}
+	return to
+}
+
+// continues checks if the header of a symbol without braces or semicolon continues after line i
+func (e BraceSymbolExtractor) continues(lines []string, i int, header int, to int) bool {
+	for next := i + 1; next < to; next++ {
+		line := strings.TrimSpace(lines[next])
+		if line == "" {
+			continue
+		}
+		if internal.IndentWidth(lines[next]) > internal.IndentWidth(lines[header]) {
+			return true
+		}
+		for _, prefix := range []string{"{", "where", "|", "&", ".", ")", "]", ">", "="} {
+			if strings.HasPrefix(line, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// braceScanner scans source lines and returns the code outside of strings and comments
+type braceScanner struct {
+	extractor *BraceSymbolExtractor
+	parens    int
+	braces    int
+	// the string or block comment the scanner is currently in
+	quote        byte
+	blockComment bool
+}
+
+func (s *braceScanner) scan(line string) []byte {
+	e := s.extractor
+	var code []byte
+	for i := 0; i < len(line); i++ {
+		switch {
+		case s.blockComment:
+			if strings.HasPrefix(line[i:], e.BlockComment[1]) {
+				s.blockComment = false
+				i += len(e.BlockComment[1]) - 1
+			}
+		case s.quote != 0:
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == s.quote {
+				s.quote = 0
+			}
+		case e.LineComment != "" && strings.HasPrefix(line[i:], e.LineComment):
+			return code
+		case e.BlockComment[0] != "" && strings.HasPrefix(line[i:], e.BlockComment[0]):
+			s.blockComment = true
+			i += len(e.BlockComment[0]) - 1
+		case strings.IndexByte(e.Quotes, line[i]) >= 0:
+			s.quote = line[i]
+		case e.CharLiterals && line[i] == '\'':
+			i += charLiteralLength(line[i:]) - 1
+		default:
+			code = append(code, line[i])
+		}
+	}
+	if s.quote != 0 && s.quote != '`' && s.quote != '"' {
+		// single quoted strings end with the line
+		s.quote = 0
+	}
+	return code
+}
+
+var (
+	charLiteralRegex = regexp.MustCompile(`^'(\\.[^']*|[^\\'])'`)
+)
+
+// charLiteralLength returns the length of a character literal
+//
+// Lifetimes (e.g. 'a) are not character literals and have length 1.
+func charLiteralLength(s string) int {
+	if loc := charLiteralRegex.FindStringIndex(s); loc != nil {
+		return loc[1]
+	}
+	return 1
+}
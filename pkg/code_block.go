@@ -2,42 +2,21 @@ package embedme
 
 import (
 	"fmt"
-	"regexp"
+	"strings"
 
 	"github.com/romnn/embedme/internal"
 	"github.com/romnn/embedme/pkg/commands"
 	"github.com/spf13/afero"
 )
 
-var (
-	// Match a code block
-	// optional: capture groups around the file extension
-	// optional: capture first line starting with //
-	blockRegex = regexp.MustCompile(
-		// multiline mode
-		// "(?m:)(?P<all>" +
-		"(?m:(?P<all>" +
-			// optional embed comment
-			"(<!--\\s*?embedme[ ]+?(?P<embedComment>\\S+?)\\s*?-->)?" +
-			// optional ignore next comment
-			"(?P<embedIgnore><!--\\s*?embedme[ -]ignore-next\\s*?-->)?" +
-			// [\\s\\S]*?)?" +
-			// todo: ensure this is not another code block here
-			"[\\s\\S]*?" +
-			// start of block and language
-			// indent counts the number of whitespace and tabs for indentation
-			"^(?P<indent>[ \t]*?)```(?P<language>\\w*)?\\s*\n" +
-			// "^(?P<indent>\\s*```(?P<language>\\w*)?.*\n" +
-			// inside code block
-			// "(?P<block>[\\s\\S]*?)^[ \t]*?```" +
-			"(?P<block>[\\s\\S]*?)```" +
-			// end of multiline mode
-			"))",
-	)
-)
-
 // CodeBlock ...
 type CodeBlock struct {
+	// FenceStart is the offset of the opening fence marker
+	FenceStart int
+	// Fence is the opening fence marker (e.g. ```)
+	Fence string
+	// ClosingFence is the closing fence marker, which can be longer than the opening fence
+	ClosingFence string
 	Start        int
 	End          int
 	StartLine    int
@@ -47,151 +26,113 @@ type CodeBlock struct {
 	EmbedComment string
 	Ignore       bool
 	Language     LanguageID
+	Info         string
+	Attributes   map[string]string
 }
 
-// Comment ...
-func (b *CodeBlock) Comment() string {
-	typ, err := b.CommentType()
-	if err != nil {
-		return ""
+// Content returns the code of the block without its indentation
+//
+// Lines that are indented less than the block keep their remaining text.
+// The indentation of the closing fence after the last line is removed.
+func (b *CodeBlock) Content() string {
+	lines := internal.Lines(b.Code)
+	if last := len(lines) - 1; strings.TrimSpace(lines[last]) == "" {
+		lines[last] = ""
 	}
-	return commentString(*typ)
+	for i, line := range lines {
+		pos := 0
+		for j := 0; j < len(b.Indent); j++ {
+			c := b.Indent[j]
+			if pos < len(line) && line[pos] == c {
+				pos++
+			} else if c != ' ' && c != '\t' {
+				break
+			}
+		}
+		lines[i] = line[pos:]
+	}
+	return strings.Join(lines, internal.DetectNewline([]byte(b.Code)))
 }
 
-// CommentType ...
-func (b *CodeBlock) CommentType() (*CommentType, error) {
-	comment, ok := CommentForLanguage[b.Language]
-	if !ok {
-		return nil, fmt.Errorf(
-			"unsupported file extension %q (must be one of %+v)",
-			b.Language,
-			SupportedLanguages,
-		)
+// FenceFor returns the fence marker that is required to enclose code
+//
+// The fence of the block is kept unless the code contains a run of the
+// fence character that is at least as long, which would close the block early.
+func (b *CodeBlock) FenceFor(code string) string {
+	if b.Fence == "" {
+		return ""
+	}
+	char := b.Fence[0]
+	length := len(b.Fence)
+	for run, i := 0, 0; i < len(code); i++ {
+		if code[i] != char {
+			run = 0
+			continue
+		}
+		run++
+		if run >= length {
+			length = run + 1
+		}
 	}
-	return &comment, nil
+	return strings.Repeat(string(char), length)
 }
 
 // EmbedCommand ...
 func (b *CodeBlock) EmbedCommand(fs afero.Fs, options *Options) (string, commands.Command, error) {
-	typ, err := b.CommentType()
-	if err != nil {
-		return "", nil, err
-	}
-
 	embedComment := b.EmbedComment
 	if embedComment == "" {
-		if firstEmbedComment, ok := FirstComment(b.Code, *typ); ok {
+		lang, err := options.language(b.Language)
+		if err != nil {
+			return "", nil, err
+		}
+		if firstEmbedComment, ok := FirstComment(b.Content(), lang); ok {
 			embedComment = firstEmbedComment
 		} else {
 			return "", nil, fmt.Errorf(
 				"no comment starting with %s in first line of %q block",
-				commentString(*typ),
+				lang.commentString(),
 				b.Language,
 			)
 		}
 	}
 
-	baseDirs := []string{options.Base, options.WorkingDir}
+	baseDirs := append(append([]string{options.Base}, options.BaseDirs...), options.WorkingDir)
+	fileCommand := commands.NewEmbedFileCommand(fs, baseDirs...)
+	fileCommand.Select = selectLines
+	outputCommand := commands.NewEmbedCommandOutputCommand(fs, options.WorkingDir)
 	commands := []commands.Command{
-		commands.NewEmbedFileCommand(fs, baseDirs...),
-		commands.NewEmbedCommandOutputCommand(fs, options.WorkingDir),
+		commands.NewEmbedGoStructCommand(fs, baseDirs...),
+		commands.NewEmbedGoDocCommand(fs, baseDirs...),
+		fileCommand,
+		outputCommand,
 	}
 	for _, cmd := range commands {
-		if err := cmd.Parse(embedComment); err == nil {
-			return embedComment, cmd, nil
+		if err := cmd.Parse(embedComment); err != nil {
+			continue
 		}
+		if cmd == outputCommand && options.DenyCommands {
+			return embedComment, nil, fmt.Errorf(
+				"refusing to run %q because commands are denied", outputCommand.Cmd,
+			)
+		}
+		return embedComment, cmd, nil
 	}
 	return embedComment, nil, fmt.Errorf(
 		"%q is not a valid command", embedComment,
 	)
 }
 
-// ExtractCodeBlocks ...
-func ExtractCodeBlocks(source string) []CodeBlock {
-	var blocks []CodeBlock
-
-	matches := internal.GetMatches(blockRegex, string(source))
-	for _, match := range matches {
-		// log.Printf("match: %+v\n\n", match)
-		var block CodeBlock
-		if b, ok := match["block"]; ok {
-			block.Start = b.Start
-			block.End = b.End
-
-			block.Code = b.Text
-			block.StartLine = internal.LineNumber(source, b.Start)
-			block.EndLine = internal.LineNumber(source, b.End)
-		}
-
-		if _, ok := match["embedIgnore"]; ok {
-			block.Ignore = true
-		}
-		// if all, ok := match["all"]; ok {
-		// 	block.Start = all.Start
-		// 	block.End = all.End
-		// }
-		if indent, ok := match["indent"]; ok {
-			block.Indent = indent.Text
-		}
-
-		if comment, ok := match["embedComment"]; ok {
-			block.EmbedComment = comment.Text
-		}
-		if language, ok := match["language"]; ok {
-			block.Language = LanguageID(language.Text)
-		}
-
-		blocks = append(blocks, block)
-	}
-
-	return blocks
-}
-
-func commentString(typ CommentType) string {
-	switch typ {
-	case CommentDoubleSlash:
-		return "//"
-	case CommentXML:
-		return "<!-- ... -->"
-	case CommentHash:
-		return "#"
-	case CommentSingleQuote:
-		return "'"
-	case CommentDoublePercent:
-		return "%%"
-	case CommentDoubleHyphens:
-		return "--"
-	}
-	return ""
-}
-
-func commentPrefixRegex(comment string) *regexp.Regexp {
-	// independent of newline type
-	return regexp.MustCompile(`^\s*` + comment + `([\s\S]*?)\r?\n`)
-}
-
-// FirstComment ...
-func FirstComment(source string, typ CommentType) (string, bool) {
-	var re *regexp.Regexp
-	switch typ {
-	case CommentNone:
+// FirstComment returns the text of the comment at the start of source
+//
+// Languages without comments have no embed comment.
+func FirstComment(source string, lang *Language) (string, bool) {
+	if !lang.HasComments() {
 		return "", true
-	case CommentDoubleSlash:
-		re = commentPrefixRegex("//")
-	case CommentXML:
-		re = regexp.MustCompile(`<!--\s*?(\S*?)\s*?-->`)
-	case CommentHash:
-		re = commentPrefixRegex("#")
-	case CommentSingleQuote:
-		re = commentPrefixRegex("'")
-	case CommentDoublePercent:
-		re = commentPrefixRegex("%%")
-	case CommentDoubleHyphens:
-		re = commentPrefixRegex("--")
 	}
-	match := re.FindStringSubmatch(source)
-	if len(match) > 0 {
-		return match[1], true
+	for _, re := range lang.firstCommentRegexes() {
+		if match := re.FindStringSubmatch(source); len(match) > 0 {
+			return match[1], true
+		}
 	}
 	return "", false
 }
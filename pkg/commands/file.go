@@ -7,10 +7,15 @@ import (
 	"strconv"
 
 	"github.com/romnn/embedme/internal"
-	"github.com/romnn/embedme/pkg/fs"
+	fsutil "github.com/romnn/embedme/pkg/fs"
 	"github.com/spf13/afero"
 )
 
+// Selector selects the lines of a file using a named selector
+//
+// For example, the selector of "main_test.go#Example_basic" is "Example_basic".
+type Selector func(path string, content []byte, selector string) ([]string, error)
+
 // EmbedFileCommand ...
 type EmbedFileCommand struct {
 	Command
@@ -18,6 +23,8 @@ type EmbedFileCommand struct {
 	Path      string
 	StartLine int
 	EndLine   int
+	Selector  string
+	Select    Selector
 	BaseDirs  []string
 	FS        afero.Fs
 }
@@ -28,6 +35,8 @@ func NewEmbedFileCommand(fs afero.Fs, baseDirs ...string) *EmbedFileCommand {
 		Path:      "",
 		StartLine: 0,
 		EndLine:   0,
+		Selector:  "",
+		Select:    nil,
 		BaseDirs:  baseDirs,
 		FS:        fs,
 	}
@@ -44,29 +53,22 @@ func (cmd *EmbedFileCommand) Lines() (int, int, bool) {
 
 // Output ...
 func (cmd *EmbedFileCommand) Output() ([]string, error) {
-	candidatePaths := []string{}
-	for _, base := range cmd.BaseDirs {
-		candidatePaths = append(candidatePaths, filepath.Join(base, cmd.Path))
-	}
-	var existingPath string
-	for _, path := range candidatePaths {
-		if err := fs.EnsureFile(cmd.FS, path); err == nil {
-			existingPath = path
-		}
-	}
-
-	if existingPath == "" {
-		return nil, fmt.Errorf(
-			"failed to embed: neither of %v exists",
-			candidatePaths,
-		)
+	path, err := resolvePath(cmd.FS, cmd.Path, cmd.BaseDirs...)
+	if err != nil {
+		return nil, err
 	}
-
-	content, err := afero.ReadFile(cmd.FS, existingPath)
+	content, err := afero.ReadFile(cmd.FS, path)
 	if err != nil {
 		return nil, err
 	}
 
+	if cmd.Selector != "" {
+		if cmd.Select == nil {
+			return nil, fmt.Errorf("selector %q is not supported", cmd.Selector)
+		}
+		return cmd.Select(path, content, cmd.Selector)
+	}
+
 	// convert to lines
 	lines := internal.Lines(string(content))
 
@@ -85,8 +87,49 @@ func (cmd *EmbedFileCommand) Output() ([]string, error) {
 	return lines, nil
 }
 
+// resolvePath resolves a file path against a list of base directories
+//
+// The last base directory that contains the file wins.
+func resolvePath(fs afero.Fs, path string, baseDirs ...string) (string, error) {
+	return resolve(fs, fsutil.EnsureFile, path, baseDirs...)
+}
+
+// resolveDir resolves a directory path against a list of base directories
+func resolveDir(fs afero.Fs, path string, baseDirs ...string) (string, error) {
+	return resolve(fs, fsutil.EnsureDir, path, baseDirs...)
+}
+
+func resolve(
+	fs afero.Fs,
+	ensure func(afero.Fs, string) error,
+	path string,
+	baseDirs ...string,
+) (string, error) {
+	candidatePaths := []string{}
+	for _, base := range baseDirs {
+		candidatePaths = append(candidatePaths, filepath.Join(base, path))
+	}
+	var existingPath string
+	for _, path := range candidatePaths {
+		if err := ensure(fs, path); err == nil {
+			existingPath = path
+		}
+	}
+
+	if existingPath == "" {
+		return "", fmt.Errorf(
+			"failed to embed: neither of %v exists",
+			candidatePaths,
+		)
+	}
+	return existingPath, nil
+}
+
 var (
-	embedPathRegex = regexp.MustCompile(`^\s*(?P<path>\S+?)(#L?(?P<start>\d+)-L?(?P<end>\d+))?\s*$`)
+	embedPathRegex = regexp.MustCompile(
+		`^\s*(?P<path>[^\s#]+)` +
+			`(#(L?(?P<start>\d+)-L?(?P<end>\d+)|(?P<selector>[A-Za-z_][\w:.\-]*)))?\s*$`,
+	)
 )
 
 // Parse ...
@@ -99,5 +142,6 @@ func (cmd *EmbedFileCommand) Parse(comment string) error {
 	cmd.Path = match["path"].Text
 	cmd.StartLine, _ = strconv.Atoi(match["start"].Text)
 	cmd.EndLine, _ = strconv.Atoi(match["end"].Text)
+	cmd.Selector = match["selector"].Text
 	return nil
 }
@@ -0,0 +1,348 @@
+package commands
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+	"github.com/spf13/afero"
+)
+
+// EmbedGoDocCommand renders the documentation of a Go package or symbol
+type EmbedGoDocCommand struct {
+	Command
+	Path     string
+	Symbol   string
+	Methods  bool
+	Examples bool
+	BaseDirs []string
+	FS       afero.Fs
+}
+
+// NewEmbedGoDocCommand ...
+func NewEmbedGoDocCommand(fs afero.Fs, baseDirs ...string) *EmbedGoDocCommand {
+	return &EmbedGoDocCommand{
+		Path:     "",
+		Symbol:   "",
+		Methods:  false,
+		Examples: false,
+		BaseDirs: baseDirs,
+		FS:       fs,
+	}
+}
+
+var (
+	embedGoDocRegex = regexp.MustCompile(
+		`^\s*godoc\s+(?P<path>[^\s#]+)(#(?P<symbol>[\w.]+))?(?P<flags>(\s+[\w-]+)*)\s*$`,
+	)
+)
+
+// Parse ...
+func (cmd *EmbedGoDocCommand) Parse(comment string) error {
+	matches := internal.GetMatches(embedGoDocRegex, comment)
+	if len(matches) < 1 {
+		return fmt.Errorf("%s is not a valid godoc command", comment)
+	}
+	match := matches[0]
+	cmd.Path = match["path"].Text
+	cmd.Symbol = match["symbol"].Text
+	for _, flag := range strings.Fields(match["flags"].Text) {
+		switch flag {
+		case "methods":
+			cmd.Methods = true
+		case "examples":
+			cmd.Examples = true
+		default:
+			return fmt.Errorf("unknown godoc flag %q", flag)
+		}
+	}
+	return nil
+}
+
+// Language returns go, the documentation is rendered as Go source
+func (cmd *EmbedGoDocCommand) Language() string {
+	return "go"
+}
+
+// Output ...
+func (cmd *EmbedGoDocCommand) Output() ([]string, error) {
+	dir, err := resolveDir(cmd.FS, cmd.Path, cmd.BaseDirs...)
+	if err != nil {
+		return nil, err
+	}
+	fset, files, err := parseGoPackage(cmd.FS, dir, cmd.Examples)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := doc.NewFromFiles(fset, packageFiles(files), cmd.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := goDocRenderer{fset: fset, pkg: pkg}
+	if cmd.Symbol == "" {
+		r.renderPackage(cmd.Examples)
+	} else if err := r.renderSymbol(cmd.Symbol, cmd.Methods, cmd.Examples); err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.lines, nil
+}
+
+// packageFiles filters the files that belong to the package (or its external test package)
+func packageFiles(files []*ast.File) []*ast.File {
+	name := ""
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name.Name, "_test") {
+			name = file.Name.Name
+			break
+		}
+	}
+	var filtered []*ast.File
+	for _, file := range files {
+		if file.Name.Name == name || file.Name.Name == name+"_test" {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// goDocRenderer renders go/doc documentation as Go source
+type goDocRenderer struct {
+	fset  *token.FileSet
+	pkg   *doc.Package
+	lines []string
+	err   error
+}
+
+func (r *goDocRenderer) add(lines ...string) {
+	r.lines = append(r.lines, lines...)
+}
+
+// separate adds an empty line unless nothing was rendered yet
+func (r *goDocRenderer) separate() {
+	if len(r.lines) > 0 {
+		r.add("")
+	}
+}
+
+func (r *goDocRenderer) comment(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	printer := r.pkg.Printer()
+	printer.TextPrefix = "// "
+	printer.TextCodePrefix = "//\t"
+	printed := printer.Text(r.pkg.Parser().Parse(text))
+	for _, line := range strings.Split(strings.TrimRight(string(printed), "\n"), "\n") {
+		r.add(strings.TrimRight(line, " "))
+	}
+}
+
+func (r *goDocRenderer) node(node interface{}) {
+	lines, err := internal.FormatGoNode(r.fset, node)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.add(lines...)
+}
+
+// signature renders a function declaration without its body and doc comment
+func (r *goDocRenderer) signature(decl *ast.FuncDecl) {
+	stripped := *decl
+	stripped.Doc = nil
+	stripped.Body = nil
+	r.node(&stripped)
+}
+
+func (r *goDocRenderer) genDecl(decl *ast.GenDecl) {
+	stripped := *decl
+	stripped.Doc = nil
+	r.node(&stripped)
+}
+
+func (r *goDocRenderer) function(fun *doc.Func, examples bool) {
+	r.separate()
+	r.comment(fun.Doc)
+	r.signature(fun.Decl)
+	if examples {
+		r.examples(fun.Examples)
+	}
+}
+
+func (r *goDocRenderer) value(value *doc.Value) {
+	r.separate()
+	r.comment(value.Doc)
+	r.genDecl(value.Decl)
+}
+
+func (r *goDocRenderer) typ(typ *doc.Type, methods bool, examples bool) {
+	r.separate()
+	r.comment(typ.Doc)
+	r.genDecl(typ.Decl)
+	if examples {
+		r.examples(typ.Examples)
+	}
+	if !methods {
+		return
+	}
+	for _, value := range concat(typ.Consts, typ.Vars) {
+		r.value(value)
+	}
+	for _, fun := range concat(typ.Funcs, typ.Methods) {
+		r.function(fun, examples)
+	}
+}
+
+func (r *goDocRenderer) examples(examples []*doc.Example) {
+	for _, example := range examples {
+		r.separate()
+		r.add(fmt.Sprintf("// Example%s:", exampleSuffix(example)))
+		body, err := r.exampleBody(example)
+		if err != nil {
+			r.err = err
+			return
+		}
+		r.add(body...)
+		if example.Output != "" || example.EmptyOutput {
+			r.add("")
+			r.add("// Output:")
+			for _, line := range internal.GoExampleOutput(example.Output) {
+				r.add(strings.TrimRight("// "+line, " "))
+			}
+		}
+	}
+}
+
+func (r *goDocRenderer) exampleBody(example *doc.Example) ([]string, error) {
+	body, ok := example.Code.(*ast.BlockStmt)
+	if !ok {
+		return internal.FormatGoNode(r.fset, example.Code)
+	}
+	return internal.GoExampleBody(r.fset, body, example.Comments)
+}
+
+func exampleSuffix(example *doc.Example) string {
+	if example.Suffix == "" {
+		return ""
+	}
+	return " (" + example.Suffix + ")"
+}
+
+func (r *goDocRenderer) renderPackage(examples bool) {
+	r.add(fmt.Sprintf("package %s", r.pkg.Name))
+	if r.pkg.Doc != "" {
+		r.add("")
+		r.comment(r.pkg.Doc)
+	}
+	if examples {
+		r.examples(r.pkg.Examples)
+	}
+
+	var summary []string
+	for _, value := range concat(r.pkg.Consts, r.pkg.Vars) {
+		summary = append(summary, valueSummary(value)...)
+	}
+	for _, fun := range r.pkg.Funcs {
+		summary = append(summary, r.funcSummary(fun, ""))
+	}
+	for _, typ := range r.pkg.Types {
+		summary = append(summary, typeSummary(typ))
+		for _, fun := range typ.Funcs {
+			summary = append(summary, r.funcSummary(fun, "    "))
+		}
+	}
+	if len(summary) > 0 {
+		r.add("")
+		r.add(summary...)
+	}
+}
+
+func (r *goDocRenderer) funcSummary(fun *doc.Func, indent string) string {
+	stripped := *fun.Decl
+	stripped.Doc = nil
+	stripped.Body = nil
+	lines, err := internal.FormatGoNode(r.fset, &stripped)
+	if err != nil {
+		r.err = err
+		return ""
+	}
+	return indent + strings.Join(lines, " ")
+}
+
+func valueSummary(value *doc.Value) []string {
+	keyword := value.Decl.Tok.String()
+	var summary []string
+	for _, name := range value.Names {
+		summary = append(summary, fmt.Sprintf("%s %s", keyword, name))
+	}
+	return summary
+}
+
+func typeSummary(typ *doc.Type) string {
+	spec, ok := typ.Decl.Specs[0].(*ast.TypeSpec)
+	if !ok {
+		return "type " + typ.Name
+	}
+	switch spec.Type.(type) {
+	case *ast.StructType:
+		return fmt.Sprintf("type %s struct{ ... }", typ.Name)
+	case *ast.InterfaceType:
+		return fmt.Sprintf("type %s interface{ ... }", typ.Name)
+	}
+	return "type " + typ.Name
+}
+
+func (r *goDocRenderer) renderSymbol(symbol string, methods bool, examples bool) error {
+	typeName, member := symbol, ""
+	if parts := strings.SplitN(symbol, ".", 2); len(parts) == 2 {
+		typeName, member = parts[0], parts[1]
+	}
+
+	for _, typ := range r.pkg.Types {
+		if typ.Name != typeName {
+			continue
+		}
+		if member == "" {
+			r.typ(typ, methods, examples)
+			return nil
+		}
+		for _, fun := range concat(typ.Funcs, typ.Methods) {
+			if fun.Name == member {
+				r.function(fun, examples)
+				return nil
+			}
+		}
+		return fmt.Errorf("%s has no method %s", typeName, member)
+	}
+
+	for _, fun := range r.pkg.Funcs {
+		if fun.Name == symbol {
+			r.function(fun, examples)
+			return nil
+		}
+	}
+	for _, value := range concat(r.pkg.Consts, r.pkg.Vars) {
+		for _, name := range value.Names {
+			if name == symbol {
+				r.value(value)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("symbol %s not found in package %s", symbol, r.pkg.Name)
+}
+
+// concat returns the elements of a and b in a new slice
+//
+// Appending to the slices of go/doc could overwrite their backing arrays.
+func concat[T any](a []T, b []T) []T {
+	return append(append(make([]T, 0, len(a)+len(b)), a...), b...)
+}
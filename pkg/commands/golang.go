@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// parseGoPackage parses all Go files of the package in a directory
+//
+// Test files are only included if tests is set.
+func parseGoPackage(fs afero.Fs, dir string, tests bool) (*token.FileSet, []*ast.File, error) {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" {
+			continue
+		}
+		if !tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := afero.ReadFile(fs, filepath.Join(dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return fset, files, nil
+}
@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+	"github.com/spf13/afero"
+)
+
+// StructTags are the struct tags listed in the reference table
+var StructTags = []string{"json", "yaml", "env"}
+
+// EmbedGoStructCommand renders a Markdown reference table for a Go struct
+type EmbedGoStructCommand struct {
+	Command
+	Path      string
+	Type      string
+	Recursive bool
+	BaseDirs  []string
+	FS        afero.Fs
+}
+
+// NewEmbedGoStructCommand ...
+func NewEmbedGoStructCommand(fs afero.Fs, baseDirs ...string) *EmbedGoStructCommand {
+	return &EmbedGoStructCommand{
+		Path:      "",
+		Type:      "",
+		Recursive: false,
+		BaseDirs:  baseDirs,
+		FS:        fs,
+	}
+}
+
+var (
+	embedGoStructRegex = regexp.MustCompile(
+		`^\s*go-struct\s+(?P<path>\S+?\.go)#(?P<type>\w+)(?P<flags>(\s+[\w-]+)*)\s*$`,
+	)
+)
+
+// Parse ...
+func (cmd *EmbedGoStructCommand) Parse(comment string) error {
+	matches := internal.GetMatches(embedGoStructRegex, comment)
+	if len(matches) < 1 {
+		return fmt.Errorf("%s is not a valid go-struct command", comment)
+	}
+	match := matches[0]
+	cmd.Path = match["path"].Text
+	cmd.Type = match["type"].Text
+	for _, flag := range strings.Fields(match["flags"].Text) {
+		switch flag {
+		case "recursive":
+			cmd.Recursive = true
+		default:
+			return fmt.Errorf("unknown go-struct flag %q", flag)
+		}
+	}
+	return nil
+}
+
+// Language returns md, the struct is rendered as a markdown table
+func (cmd *EmbedGoStructCommand) Language() string {
+	return "md"
+}
+
+// Output ...
+func (cmd *EmbedGoStructCommand) Output() ([]string, error) {
+	path, err := resolvePath(cmd.FS, cmd.Path, cmd.BaseDirs...)
+	if err != nil {
+		return nil, err
+	}
+	structs, err := cmd.parseStructs(path)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := structs[cmd.Type]
+	if !ok {
+		return nil, fmt.Errorf("struct %s not found in %s", cmd.Type, cmd.Path)
+	}
+
+	table := goStructTable{
+		structs:   structs,
+		recursive: cmd.Recursive,
+		visited:   map[string]bool{cmd.Type: true},
+	}
+	table.addFields("", root)
+
+	lines := []string{
+		"| Field | Type | Tags | Description |",
+		"| ----- | ---- | ---- | ----------- |",
+	}
+	return append(lines, table.rows...), nil
+}
+
+// parseStructs parses all struct types declared in the package of a file
+func (cmd *EmbedGoStructCommand) parseStructs(path string) (map[string]*ast.StructType, error) {
+	_, files, err := parseGoPackage(cmd.FS, filepath.Dir(path), false)
+	if err != nil {
+		return nil, err
+	}
+	structs := make(map[string]*ast.StructType)
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if spec, ok := node.(*ast.TypeSpec); ok {
+				if typ, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = typ
+				}
+			}
+			return true
+		})
+	}
+	return structs, nil
+}
+
+// goStructTable collects the rows of a struct reference table
+type goStructTable struct {
+	structs   map[string]*ast.StructType
+	recursive bool
+	visited   map[string]bool
+	rows      []string
+}
+
+func (t *goStructTable) addFields(prefix string, typ *ast.StructType) {
+	for _, field := range typ.Fields.List {
+		names := []string{}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(field.Names) == 0 {
+			// embedded field
+			names = append(names, embeddedFieldName(field.Type))
+		}
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			t.addField(prefix+name, field)
+		}
+	}
+}
+
+func (t *goStructTable) addField(name string, field *ast.Field) {
+	typeName := types.ExprString(field.Type)
+	nested, isInline := field.Type.(*ast.StructType)
+	if isInline {
+		typeName = "struct"
+	}
+
+	t.rows = append(t.rows, fmt.Sprintf(
+		"| `%s` | `%s` | %s | %s |",
+		name, typeName, fieldTags(field), escapeTableCell(fieldDoc(field)),
+	))
+
+	if isInline {
+		t.addFields(name+".", nested)
+		return
+	}
+	if !t.recursive {
+		return
+	}
+	ident := strings.TrimPrefix(typeName, "*")
+	if nested, ok := t.structs[ident]; ok && !t.visited[ident] {
+		t.visited[ident] = true
+		t.addFields(name+".", nested)
+		delete(t.visited, ident)
+	}
+}
+
+func embeddedFieldName(expr ast.Expr) string {
+	switch typ := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedFieldName(typ.X)
+	case *ast.SelectorExpr:
+		return typ.Sel.Name
+	}
+	return types.ExprString(expr)
+}
+
+func fieldTags(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	value, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	tag := reflect.StructTag(value)
+	var tags []string
+	for _, key := range StructTags {
+		if value, ok := tag.Lookup(key); ok {
+			tags = append(tags, fmt.Sprintf("`%s:%q`", key, value))
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+func fieldDoc(field *ast.Field) string {
+	doc := field.Doc.Text()
+	if doc == "" {
+		doc = field.Comment.Text()
+	}
+	return strings.Join(strings.Fields(doc), " ")
+}
+
+func escapeTableCell(cell string) string {
+	return strings.ReplaceAll(cell, "|", "\\|")
+}
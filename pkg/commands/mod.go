@@ -9,3 +9,9 @@ type Command interface {
 	// If the comment cannot be parsed, an error is returned.
 	Parse(comment string) error
 }
+
+// LanguageHinter is implemented by commands that know the language of their output
+type LanguageHinter interface {
+	// Language returns the language of the output (e.g. go)
+	Language() string
+}
@@ -0,0 +1,498 @@
+package embedme
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	fsutil "github.com/romnn/embedme/pkg/fs"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ConfigFileNames are the names of project configuration files
+	ConfigFileNames = []string{".embedme.yaml", ".embedme.yml"}
+)
+
+// DocumentSettings are the settings of a configuration file that apply to documents
+type DocumentSettings struct {
+	// Base is the source files directory prefix for shorter code block comments
+	Base string `yaml:"base,omitempty"`
+	// BaseDirs are additional directories that embedded files are resolved in
+	BaseDirs []string `yaml:"base_dirs,omitempty"`
+	// Format is the document format (e.g. markdown or rst)
+	Format string `yaml:"format,omitempty"`
+	// StripCallouts removes AsciiDoc callouts from code embedded into documents of other formats
+	StripCallouts *bool `yaml:"strip_callouts,omitempty"`
+	// Languages override the known languages with the same aliases
+	Languages []Language `yaml:"languages,omitempty"`
+	// OnlyBlocks are the selectors of the only code blocks that are embedded into
+	OnlyBlocks []string `yaml:"only_blocks,omitempty"`
+	// SkipBlocks are the selectors of the code blocks that are not embedded into
+	SkipBlocks []string `yaml:"skip_blocks,omitempty"`
+	// Commands allows or denies embedding the output of commands (e.g. $ ls)
+	Commands string `yaml:"commands,omitempty"`
+	// Normalizers normalize the embedded code in order (e.g. dedent)
+	Normalizers []string `yaml:"normalizers,omitempty"`
+}
+
+const (
+	// CommandsAllow allows embedding the output of commands
+	CommandsAllow = "allow"
+	// CommandsDeny refuses to embed the output of commands
+	CommandsDeny = "deny"
+)
+
+// ConfigSettings are the settings of a configuration file or one of its profiles
+type ConfigSettings struct {
+	DocumentSettings `yaml:",inline"`
+	// Sources are the patterns of the documents that are processed if no documents are given
+	Sources []string `yaml:"sources,omitempty"`
+	// IgnoreFiles are the names of the ignore files in each directory (e.g. .gitignore)
+	IgnoreFiles []string `yaml:"ignore_files,omitempty"`
+	// NoIgnore disables all ignore files
+	NoIgnore *bool `yaml:"no_ignore,omitempty"`
+	// Overrides override the settings for the documents that match path patterns
+	Overrides []ConfigOverride `yaml:"overrides,omitempty"`
+
+	// dir is the directory of relative paths
+	dir string
+}
+
+// ConfigOverride overrides the settings for the documents that match path patterns
+type ConfigOverride struct {
+	// Paths are the patterns of the documents relative to the configuration file (e.g. docs/**/*.md)
+	Paths            []string `yaml:"paths"`
+	DocumentSettings `yaml:",inline"`
+}
+
+// Config is a project configuration file (e.g. .embedme.yaml)
+//
+// Paths in the configuration file are relative to its directory.
+type Config struct {
+	// Path is the path of the configuration file
+	Path           string `yaml:"-"`
+	ConfigSettings `yaml:",inline"`
+	// Profiles override the settings when they are selected (e.g. ci)
+	Profiles map[string]ConfigSettings `yaml:"profiles,omitempty"`
+}
+
+// FindConfig finds the configuration file of a directory or its parents
+//
+// The path is empty if there is no configuration file.
+func FindConfig(fs afero.Fs, dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range ConfigFileNames {
+			path := filepath.Join(dir, name)
+			info, err := fs.Stat(path)
+			if err == nil && !info.IsDir() {
+				return path, nil
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return "", fmt.Errorf("failed to find configuration file: %v", err)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig loads a configuration file
+func LoadConfig(fs afero.Fs, path string) (*Config, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %v", err)
+	}
+	return ParseConfig(path, data)
+}
+
+var (
+	// Matches the line of a YAML error (e.g. yaml: line 3: mapping values are not allowed)
+	yamlErrorLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	// Matches the message of unknown fields of strict decoding
+	yamlUnknownFieldRegex = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// ParseConfig parses a configuration file
+//
+// Errors point to the line of the configuration file (e.g. .embedme.yaml:3: ...).
+func ParseConfig(path string, data []byte) (*Config, error) {
+	config := Config{Path: path}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, configYAMLError(path, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, configYAMLError(path, err)
+	}
+	if err := config.validate(); err != nil {
+		var invalid *configError
+		if errors.As(err, &invalid) {
+			return nil, fmt.Errorf("%s:%d: %v", path, nodeLine(&root, invalid.keys), invalid.err)
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// configYAMLError formats the first YAML error with the path and line of the configuration file
+func configYAMLError(path string, err error) error {
+	message := err.Error()
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		message = typeErr.Errors[0]
+	}
+	match := yamlErrorLineRegex.FindStringSubmatch(message)
+	if match == nil {
+		return fmt.Errorf("%s: %s", path, message)
+	}
+	message = match[2]
+	if field := yamlUnknownFieldRegex.FindStringSubmatch(message); field != nil {
+		message = fmt.Sprintf("unknown setting %q", field[1])
+	}
+	return fmt.Errorf("%s:%s: %s", path, match[1], message)
+}
+
+// configError is an invalid setting at the keys of a configuration file
+type configError struct {
+	keys []interface{}
+	err  error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+// invalidSetting returns an error for the setting at keys
+func invalidSetting(err error, keys ...interface{}) error {
+	return &configError{keys: keys, err: err}
+}
+
+// prefixed prefixes the keys of a setting error with the keys of its parent
+func prefixed(err error, keys ...interface{}) error {
+	var invalid *configError
+	if errors.As(err, &invalid) {
+		return &configError{keys: append(keys, invalid.keys...), err: invalid.err}
+	}
+	return err
+}
+
+// nodeLine returns the line of the value at keys (strings for mappings and ints for sequences)
+//
+// The line of the deepest existing value is returned.
+func nodeLine(node *yaml.Node, keys []interface{}) int {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		next := childNode(node, key)
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return node.Line
+}
+
+func childNode(node *yaml.Node, key interface{}) *yaml.Node {
+	switch key := key.(type) {
+	case string:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	case int:
+		if node.Kind == yaml.SequenceNode && key < len(node.Content) {
+			return node.Content[key]
+		}
+	}
+	return nil
+}
+
+// validate checks the settings and the profiles of a configuration file
+func (c *Config) validate() error {
+	if err := c.ConfigSettings.validate(); err != nil {
+		return err
+	}
+	for _, name := range c.profileNames() {
+		profile := c.Profiles[name]
+		if err := profile.validate(); err != nil {
+			return prefixed(err, "profiles", name)
+		}
+	}
+	return nil
+}
+
+func (s *ConfigSettings) validate() error {
+	if err := s.DocumentSettings.validate(); err != nil {
+		return err
+	}
+	for i, pattern := range s.Sources {
+		if _, err := fsutil.Match(pattern, ""); err != nil {
+			return invalidSetting(err, "sources", i)
+		}
+	}
+	for i := range s.Overrides {
+		override := &s.Overrides[i]
+		if len(override.Paths) == 0 {
+			return invalidSetting(fmt.Errorf("override has no paths"), "overrides", i)
+		}
+		for j, pattern := range override.Paths {
+			if _, err := fsutil.Match(pattern, ""); err != nil {
+				return invalidSetting(err, "overrides", i, "paths", j)
+			}
+		}
+		if err := override.DocumentSettings.validate(); err != nil {
+			return prefixed(err, "overrides", i)
+		}
+	}
+	return nil
+}
+
+func (s *DocumentSettings) validate() error {
+	if s.Format != "" {
+		if _, err := FormatForName(s.Format); err != nil {
+			return invalidSetting(err, "format")
+		}
+	}
+	for i := range s.Languages {
+		if err := s.Languages[i].validate(); err != nil {
+			return invalidSetting(err, "languages", i)
+		}
+	}
+	for i, selector := range s.OnlyBlocks {
+		if _, err := ParseBlockSelector(selector); err != nil {
+			return invalidSetting(err, "only_blocks", i)
+		}
+	}
+	for i, selector := range s.SkipBlocks {
+		if _, err := ParseBlockSelector(selector); err != nil {
+			return invalidSetting(err, "skip_blocks", i)
+		}
+	}
+	if s.Commands != "" && s.Commands != CommandsAllow && s.Commands != CommandsDeny {
+		err := fmt.Errorf("invalid commands %q (must be %s or %s)", s.Commands, CommandsAllow, CommandsDeny)
+		return invalidSetting(err, "commands")
+	}
+	for i, name := range s.Normalizers {
+		if _, err := ParseNormalizer(name); err != nil {
+			return invalidSetting(err, "normalizers", i)
+		}
+	}
+	return nil
+}
+
+// profileNames returns the sorted names of the profiles
+func (c *Config) profileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Settings returns the settings overridden by a profile
+//
+// The settings of the configuration file are returned if the profile is empty.
+func (c *Config) Settings(profile string) (ConfigSettings, error) {
+	settings := c.ConfigSettings
+	if profile != "" {
+		overrides, ok := c.Profiles[profile]
+		if !ok {
+			return settings, fmt.Errorf(
+				"unknown profile %q (%s has profiles %v)",
+				profile, c.Path, c.profileNames(),
+			)
+		}
+		settings.merge(overrides)
+	}
+	settings.dir = filepath.Dir(c.Path)
+	return settings, nil
+}
+
+// merge overrides the settings with the settings of a profile
+//
+// Languages and overrides are added, other lists are replaced.
+func (s *ConfigSettings) merge(profile ConfigSettings) {
+	s.DocumentSettings = s.DocumentSettings.merged(profile.DocumentSettings)
+	if profile.Sources != nil {
+		s.Sources = profile.Sources
+	}
+	if profile.IgnoreFiles != nil {
+		s.IgnoreFiles = profile.IgnoreFiles
+	}
+	if profile.NoIgnore != nil {
+		s.NoIgnore = profile.NoIgnore
+	}
+	s.Overrides = append(append([]ConfigOverride{}, s.Overrides...), profile.Overrides...)
+}
+
+// merged returns the settings overridden by other settings
+func (s DocumentSettings) merged(other DocumentSettings) DocumentSettings {
+	if other.Base != "" {
+		s.Base = other.Base
+	}
+	if other.BaseDirs != nil {
+		s.BaseDirs = other.BaseDirs
+	}
+	if other.Format != "" {
+		s.Format = other.Format
+	}
+	if other.StripCallouts != nil {
+		s.StripCallouts = other.StripCallouts
+	}
+	s.Languages = append(append([]Language{}, s.Languages...), other.Languages...)
+	if other.OnlyBlocks != nil {
+		s.OnlyBlocks = other.OnlyBlocks
+	}
+	if other.SkipBlocks != nil {
+		s.SkipBlocks = other.SkipBlocks
+	}
+	if other.Commands != "" {
+		s.Commands = other.Commands
+	}
+	if other.Normalizers != nil {
+		s.Normalizers = other.Normalizers
+	}
+	return s
+}
+
+// Apply applies the settings to options and a source finder, which can be nil
+func (s *ConfigSettings) Apply(options *Options, finder *SourceFinder) error {
+	if err := s.DocumentSettings.apply(options, s.dir); err != nil {
+		return err
+	}
+	for _, override := range s.Overrides {
+		options.Overrides = append(options.Overrides, OptionsOverride{
+			Dir:      s.dir,
+			Paths:    override.Paths,
+			Settings: override.DocumentSettings,
+		})
+	}
+	if finder == nil {
+		return nil
+	}
+	if len(s.Sources) > 0 {
+		finder.Sources = s.Sources
+		finder.SourcesDir = s.dir
+	}
+	if s.IgnoreFiles != nil {
+		finder.IgnoreFiles = s.IgnoreFiles
+	}
+	if s.NoIgnore != nil {
+		finder.NoIgnore = *s.NoIgnore
+	}
+	return nil
+}
+
+// apply applies the settings to options, relative paths are relative to dir
+func (s *DocumentSettings) apply(options *Options, dir string) error {
+	if s.Base != "" {
+		options.Base = configPath(dir, s.Base)
+	}
+	if s.BaseDirs != nil {
+		options.BaseDirs = nil
+		for _, base := range s.BaseDirs {
+			options.BaseDirs = append(options.BaseDirs, configPath(dir, base))
+		}
+	}
+	if s.Format != "" {
+		options.Format = s.Format
+	}
+	if s.StripCallouts != nil {
+		options.StripCallouts = *s.StripCallouts
+	}
+	if s.Commands != "" {
+		options.DenyCommands = s.Commands == CommandsDeny
+	}
+	options.Languages = append(append([]Language{}, options.Languages...), s.Languages...)
+	var err error
+	if s.OnlyBlocks != nil {
+		if options.OnlyBlocks, err = ParseBlockSelectors(s.OnlyBlocks); err != nil {
+			return err
+		}
+	}
+	if s.SkipBlocks != nil {
+		if options.SkipBlocks, err = ParseBlockSelectors(s.SkipBlocks); err != nil {
+			return err
+		}
+	}
+	if s.Normalizers != nil {
+		if options.Normalizers, err = ParseNormalizers(s.Normalizers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configPath returns a path of the configuration file relative to dir
+func configPath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// OptionsOverride overrides options for the documents that match path patterns
+type OptionsOverride struct {
+	// Dir is the directory of relative patterns and paths (the working directory by default)
+	Dir string
+	// Paths are the patterns of the documents (e.g. docs/**/*.md)
+	Paths []string
+	// Settings override the options
+	Settings DocumentSettings
+}
+
+// matches checks if a document matches the patterns of the override relative to dir
+func (o *OptionsOverride) matches(dir string, absPath string) bool {
+	rel, err := filepath.Rel(dir, absPath)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range o.Paths {
+		if ok, _ := fsutil.Match(pattern, filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// forPath returns the options overridden for a document
+func (o *Options) forPath(absPath string) (Options, error) {
+	options := *o
+	for i := range o.Overrides {
+		override := &o.Overrides[i]
+		dir := override.Dir
+		if dir == "" {
+			dir = o.WorkingDir
+		}
+		if !override.matches(dir, absPath) {
+			continue
+		}
+		if err := override.Settings.apply(&options, dir); err != nil {
+			return options, fmt.Errorf("invalid override for %v: %v", override.Paths, err)
+		}
+	}
+	return options, nil
+}
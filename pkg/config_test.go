@@ -0,0 +1,254 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestParseConfigErrors(t *testing.T) {
+	cases := []struct {
+		config   string
+		expected string
+	}{
+		{
+			config:   "base: docs\nbases: []\n",
+			expected: `.embedme.yaml:2: unknown setting "bases"`,
+		},
+		{
+			config:   "profiles:\n  ci:\n    commands: never\n",
+			expected: `.embedme.yaml:3: invalid commands "never"`,
+		},
+		{
+			config:   "normalizers:\n  - dedent\n  - unknown\n",
+			expected: `.embedme.yaml:3: unknown normalizer "unknown"`,
+		},
+		{
+			config:   "format: unknown\n",
+			expected: ".embedme.yaml:1: ",
+		},
+		{
+			config:   "profiles:\n  ci:\n    only_blocks:\n      - 42\n      - \"(\"\n",
+			expected: ".embedme.yaml:5: invalid block selector",
+		},
+		{
+			config:   "overrides:\n  - base: docs\n",
+			expected: ".embedme.yaml:2: override has no paths",
+		},
+		{
+			config:   "sources: docs\n",
+			expected: ".embedme.yaml:1: ",
+		},
+	}
+	for _, c := range cases {
+		_, err := ParseConfig(".embedme.yaml", []byte(c.config))
+		if err == nil {
+			t.Errorf("expected an error for config %q", c.config)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("expected error %q to start with %q", err.Error(), c.expected)
+		}
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	config, err := ParseConfig("/repo/.embedme.yaml", []byte(""+
+		"base: src\n"+
+		"sources: [\"docs/**/*.md\"]\n"+
+		"skip_blocks: [\"1\"]\n"+
+		"profiles:\n"+
+		"  ci:\n"+
+		"    strip_callouts: true\n"+
+		"    no_ignore: true\n"+
+		"    skip_blocks: []\n",
+	))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if _, err := config.Settings("release"); err == nil {
+		t.Fatalf("expected an error for an unknown profile")
+	}
+
+	settings, err := config.Settings("ci")
+	if err != nil {
+		t.Fatalf("failed to select profile: %v", err)
+	}
+	options := NewDefaultOptions()
+	finder := NewSourceFinder()
+	if err := settings.Apply(&options, &finder); err != nil {
+		t.Fatalf("failed to apply settings: %v", err)
+	}
+	if options.Base != "/repo/src" {
+		t.Errorf("expected base /repo/src but got %q", options.Base)
+	}
+	if !options.StripCallouts || len(options.SkipBlocks) != 0 {
+		t.Errorf("expected the ci profile to strip callouts and skip no blocks")
+	}
+	if diff := cmp.Diff([]string{"docs/**/*.md"}, finder.Sources); diff != "" || finder.SourcesDir != "/repo" {
+		t.Errorf("unexpected sources in %q: %s", finder.SourcesDir, diff)
+	}
+	if !finder.NoIgnore {
+		t.Errorf("expected the ci profile to disable ignore files")
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/.embedme.yml", []byte(""), 0644)
+	fs.MkdirAll("/repo/docs/nested", 0755)
+
+	path, err := FindConfig(fs, "/repo/docs/nested")
+	if err != nil {
+		t.Fatalf("failed to find config: %v", err)
+	}
+	if path != filepath.FromSlash("/repo/.embedme.yml") {
+		t.Fatalf("expected config /repo/.embedme.yml but got %q", path)
+	}
+	if path, _ := FindConfig(afero.NewMemMapFs(), "/repo"); path != "" {
+		t.Fatalf("expected no config but got %q", path)
+	}
+}
+
+func TestFindSourcesOfConfig(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, file := range []string{"/repo/readme.md", "/repo/docs/a.md", "/repo/docs/nested/b.md", "/repo/docs/c.txt"} {
+		afero.WriteFile(fs, file, []byte(""), 0644)
+	}
+	config, err := ParseConfig("/repo/.embedme.yaml", []byte("sources: [\"docs/**/*.md\"]\n"))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	settings, _ := config.Settings("")
+
+	options := NewDefaultOptions()
+	finder := NewSourceFinder()
+	finder.WorkingDir = "/repo/docs/nested"
+	if err := settings.Apply(&options, &finder); err != nil {
+		t.Fatalf("failed to apply settings: %v", err)
+	}
+	sources, err := finder.FindSources(fs)
+	if err != nil {
+		t.Fatalf("failed to find sources: %v", err)
+	}
+	expected := SourceMap{
+		filepath.FromSlash("/repo/docs/a.md"):        true,
+		filepath.FromSlash("/repo/docs/nested/b.md"): true,
+	}
+	if diff := cmp.Diff(expected, sources); diff != "" {
+		t.Fatalf("unexpected sources: %s", diff)
+	}
+}
+
+func TestEmbedConfigOverrides(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/src/main.go", []byte("package src\n"), 0644)
+	afero.WriteFile(fs, "/repo/lib/main.go", []byte("package lib\n"), 0644)
+
+	config, err := ParseConfig("/repo/.embedme.yaml", []byte(""+
+		"overrides:\n"+
+		"  - paths: [\"docs/**\"]\n"+
+		"    base: lib\n",
+	))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	settings, _ := config.Settings("")
+	options := NewDefaultOptions()
+	options.WorkingDir = "/repo"
+	options.Base = "/repo/src"
+	if err := settings.Apply(&options, nil); err != nil {
+		t.Fatalf("failed to apply settings: %v", err)
+	}
+	embedder := Embedder{Options: options, FS: fs}
+
+	fence := "```"
+	readme := "<!-- embedme main.go -->\n" + fence + "go\n" + fence + "\n"
+	cases := map[string]string{
+		"readme.md":      "package src\n",
+		"docs/readme.md": "package lib\n",
+	}
+	for path, code := range cases {
+		embedded, err := embedder.Embed([]byte(readme), filepath.Join("/repo", path), path)
+		if err != nil {
+			t.Fatalf("failed to embed %s: %v", path, err)
+		}
+		expected := "<!-- embedme main.go -->\n" + fence + "go\n" + code + fence + "\n"
+		if diff := cmp.Diff(embedded, expected); diff != "" {
+			t.Errorf("unexpected embedded markdown of %s: %s", path, diff)
+		}
+	}
+}
+
+func TestEmbedConfigBaseDirsAndNormalizers(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/examples/greet.go", []byte("\tgreet()  \n\n\n\tdone()\n"), 0644)
+
+	config, err := ParseConfig("/repo/docs/.embedme.yaml", []byte(""+
+		"base_dirs: [../examples]\n"+
+		"normalizers: [dedent, trim_trailing_whitespace, collapse_blank_lines]\n",
+	))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	settings, _ := config.Settings("")
+	options := NewDefaultOptions()
+	options.WorkingDir = "/repo/docs"
+	if err := settings.Apply(&options, nil); err != nil {
+		t.Fatalf("failed to apply settings: %v", err)
+	}
+	if diff := cmp.Diff(options.BaseDirs, []string{"/repo/examples"}); diff != "" {
+		t.Fatalf("unexpected base dirs: %s", diff)
+	}
+	embedder := Embedder{Options: options, FS: fs}
+
+	fence := "```"
+	readme := "<!-- embedme greet.go -->\n" + fence + "go\n" + fence + "\n"
+	embedded, err := embedder.Embed([]byte(readme), "/repo/docs/readme.md", "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	expected := "<!-- embedme greet.go -->\n" + fence + "go\ngreet()\n\ndone()\n" + fence + "\n"
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Errorf("unexpected embedded markdown: %s", diff)
+	}
+}
+
+func TestEmbedDeniedCommands(t *testing.T) {
+	config, err := ParseConfig("/repo/.embedme.yaml", []byte(""+
+		"commands: allow\n"+
+		"profiles:\n"+
+		"  ci:\n"+
+		"    commands: deny\n",
+	))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	fence := "```"
+	readme := "<!-- embedme $ echo embedme -->\n" + fence + "sh\n" + fence + "\n"
+	for profile, denied := range map[string]bool{"": false, "ci": true} {
+		settings, _ := config.Settings(profile)
+		options := NewDefaultOptions()
+		options.WorkingDir = "/repo"
+		if err := settings.Apply(&options, nil); err != nil {
+			t.Fatalf("failed to apply settings: %v", err)
+		}
+		// commands run in the working directory on disk
+		options.WorkingDir = t.TempDir()
+		if options.DenyCommands != denied {
+			t.Errorf("expected profile %q to deny commands=%v", profile, denied)
+		}
+		embedder := Embedder{Options: options, FS: afero.NewMemMapFs()}
+		embedded, err := embedder.Embed([]byte(readme), "/repo/readme.md", "readme.md")
+		if err != nil {
+			t.Fatalf("failed to embed: %v", err)
+		}
+		if ran := strings.Contains(embedded, "\nembedme\n"); ran == denied {
+			t.Errorf("expected profile %q to run the command=%v, got %q", profile, !denied, embedded)
+		}
+	}
+}
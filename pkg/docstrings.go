@@ -0,0 +1,275 @@
+package embedme
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+)
+
+var (
+	// Matches an embed comment in a Go comment
+	goEmbedCommentRegex = regexp.MustCompile(`^\s*//\s*embedme\s+(.+?)\s*$`)
+	// Matches an ignore next comment in a Go comment
+	goIgnoreRegex = regexp.MustCompile(`^\s*//\s*embedme[ -]ignore-(next|start|end|file)\s*$`)
+	// Matches the start of a Python docstring
+	pythonDocstringRegex = regexp.MustCompile(`^\s*[rRuU]?("""|''')`)
+)
+
+// GoDocFormat is the format of code blocks in the comments of Go source files
+//
+// An embed comment (// embedme path) in a doc comment is followed by an
+// indented code block (//<tab>code) that contains the embedded code.
+// The code block is created if it does not exist yet. Comments that are
+// not doc comments and string literals are never changed.
+type GoDocFormat struct{}
+
+// Name ...
+func (GoDocFormat) Name() string {
+	return "godoc"
+}
+
+// ParseDirective parses // embedme path and // embedme-ignore-... comments
+func (GoDocFormat) ParseDirective(line string) (Directive, bool) {
+	return parseDirective(line, goIgnoreRegex, goEmbedCommentRegex)
+}
+
+// CodeBlocks extracts the code blocks that follow embed comments
+func (f GoDocFormat) CodeBlocks(source string) []CodeBlock {
+	lines := sourceLines(source)
+	docLines := goDocCommentLines(source)
+	var blocks []CodeBlock
+	var directive Directive
+	for i := 0; i < len(lines); i++ {
+		if !docLines[i] || !directive.update(f, lines[i].text) {
+			directive = Directive{}
+			continue
+		}
+		if directive.Command == "" {
+			continue
+		}
+		prefix := lines[i].text[:strings.Index(lines[i].text, "//")+2]
+		block, last := goCommentBlock(source, lines, docLines, i, prefix)
+		block.EmbedComment = directive.Command
+		block.Ignore = directive.IgnoreNext
+		blocks = append(blocks, block)
+		directive = Directive{}
+		i = last
+	}
+	return blocks
+}
+
+// goDocCommentLines returns the indices of the lines of // comments in doc comments
+//
+// Source files that do not parse keep the doc comments before the syntax error.
+func goDocCommentLines(source string) map[int]bool {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", source, parser.ParseComments)
+	lines := map[int]bool{}
+	if file == nil {
+		return lines
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if doc := goDocComment(node); doc != nil {
+			for _, comment := range doc.List {
+				if strings.HasPrefix(comment.Text, "//") {
+					lines[fset.Position(comment.Pos()).Line-1] = true
+				}
+			}
+		}
+		return true
+	})
+	return lines
+}
+
+// goDocComment returns the doc comment of a node
+func goDocComment(node ast.Node) *ast.CommentGroup {
+	switch node := node.(type) {
+	case *ast.File:
+		return node.Doc
+	case *ast.GenDecl:
+		return node.Doc
+	case *ast.FuncDecl:
+		return node.Doc
+	case *ast.TypeSpec:
+		return node.Doc
+	case *ast.ValueSpec:
+		return node.Doc
+	case *ast.Field:
+		return node.Doc
+	}
+	return nil
+}
+
+// goCommentBlock finds the indented code block that follows the embed comment in line i
+//
+// It returns the block and its last line.
+func goCommentBlock(
+	source string,
+	lines []sourceLine,
+	docLines map[int]bool,
+	i int,
+	prefix string,
+) (CodeBlock, int) {
+	start, end := i+1, i+1
+	for j := i + 1; j < len(lines) && docLines[j] && strings.HasPrefix(lines[j].text, prefix); j++ {
+		rest := lines[j].text[len(prefix):]
+		if strings.TrimSpace(rest) == "" {
+			continue
+		}
+		if !strings.HasPrefix(rest, "\t") && !strings.HasPrefix(rest, "  ") {
+			break
+		}
+		if start == end {
+			// first line of the code block
+			start = j
+		}
+		end = j + 1
+	}
+	block := CodeBlock{
+		Language: "txt",
+		Indent:   prefix + "\t",
+	}
+	if start == end {
+		// empty block after the embed comment
+		block.Start, block.End = lines[i].end, lines[i].end
+		block.StartLine, block.EndLine = i+2, i+2
+		return block, i
+	}
+	block.Start, block.End = lines[start].start, lines[end-1].end
+	block.StartLine, block.EndLine = start+1, end
+	block.Code = source[block.Start:block.End]
+	var code []string
+	for _, line := range lines[start:end] {
+		code = append(code, strings.TrimPrefix(line.text, prefix))
+	}
+	dedented := internal.Dedent(code)
+	block.Indent = prefix + code[0][:len(code[0])-len(dedented[0])]
+	return block, end - 1
+}
+
+// Replace removes trailing whitespace and separates new code blocks from the comment text
+func (GoDocFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	if code == block.Code {
+		return block.Start, block.End, code
+	}
+	code = trimTrailingWhitespace(strings.TrimSuffix(code, block.Indent))
+	if block.Code == "" {
+		newline := internal.DetectNewline([]byte(source))
+		prefix := strings.TrimRight(block.Indent, " \t")
+		code = prefix + newline + code
+		if !strings.HasSuffix(source[:block.Start], "\n") {
+			code = newline + code
+		}
+		if next := source[block.End:]; strings.HasPrefix(next, prefix) &&
+			strings.TrimSpace(strings.SplitN(next, "\n", 2)[0]) != strings.TrimSpace(prefix) {
+			// code blocks end with a blank comment line before the text continues
+			code += prefix + newline
+		}
+	}
+	return block.Start, block.End, code
+}
+
+// trimTrailingWhitespace removes the trailing whitespace of all lines but keeps their line endings
+func trimTrailingWhitespace(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t\r")
+		lines[i] = trimmed + line[len(strings.TrimRight(line, "\r")):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PythonDocstringFormat is the format of code blocks in the docstrings of Python source files
+//
+// Docstrings are scanned as reStructuredText, so examples can be code-block
+// directives or literal blocks, which default to Python.
+type PythonDocstringFormat struct{}
+
+// Name ...
+func (PythonDocstringFormat) Name() string {
+	return "docstring"
+}
+
+// ParseDirective parses .. embedme: path and .. embedme-ignore-... comments
+func (PythonDocstringFormat) ParseDirective(line string) (Directive, bool) {
+	return ReStructuredTextFormat{}.ParseDirective(line)
+}
+
+// CodeBlocks extracts the code blocks of all docstrings
+func (PythonDocstringFormat) CodeBlocks(source string) []CodeBlock {
+	var blocks []CodeBlock
+	index := internal.NewLineIndex(source)
+	for _, docstring := range pythonDocstrings(source) {
+		content := source[docstring.start:docstring.end]
+		scanner := rstScanner{source: content, lines: sourceLines(content), highlight: "python"}
+		scanner.scan()
+		line := index.Line(docstring.start) - 1
+		for _, block := range scanner.blocks {
+			block.Start += docstring.start
+			block.End += docstring.start
+			block.StartLine += line
+			block.EndLine += line
+			block.ClosingFence = docstring.quote
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// Replace ...
+func (PythonDocstringFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	return ReStructuredTextFormat{}.Replace(source, block, code)
+}
+
+// Escape refuses to embed lines that would end the docstring
+func (PythonDocstringFormat) Escape(block *CodeBlock, lines []string) ([]string, error) {
+	for _, line := range lines {
+		if strings.Contains(line, block.ClosingFence) {
+			return nil, fmt.Errorf(
+				"refusing to embed %q as it contains %s",
+				line, block.ClosingFence,
+			)
+		}
+	}
+	return lines, nil
+}
+
+// pythonDocstring is the content of a docstring between its quotes
+type pythonDocstring struct {
+	quote string
+	start int
+	end   int
+}
+
+// pythonDocstrings finds the multi-line strings that start a statement
+func pythonDocstrings(source string) []pythonDocstring {
+	var docstrings []pythonDocstring
+	var scanner pythonScanner
+	lines := sourceLines(source)
+	for i := 0; i < len(lines); i++ {
+		match := pythonDocstringRegex.FindStringSubmatchIndex(lines[i].text)
+		if scanner.quote != "" || match == nil {
+			scanner.scan(lines[i].text)
+			continue
+		}
+		quote := lines[i].text[match[2]:match[3]]
+		start := lines[i].start + match[1]
+		j := i
+		for scanner.scan(lines[j].text); scanner.quote != "" && j+1 < len(lines); {
+			j++
+			scanner.scan(lines[j].text)
+		}
+		if j == i || scanner.quote != "" {
+			continue
+		}
+		end := lines[j].start + strings.Index(lines[j].text, quote)
+		docstrings = append(docstrings, pythonDocstring{quote: quote, start: start, end: end})
+		i = j
+	}
+	return docstrings
+}
@@ -0,0 +1,259 @@
+package embedme
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/romnn/embedme/internal"
+	"github.com/spf13/afero"
+)
+
+func TestEmbedGoDocComments(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/examples/basic.go", []byte(strings.TrimSpace(`
+package main
+
+func main() {
+	greet("embedme")
+}
+	`)), 0644)
+	afero.WriteFile(fs, "/work/dir/config.yaml", []byte("name: embedme\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	source := strings.TrimSpace(`
+// Package greet greets.
+//
+// embedme examples/basic.go
+//
+//	package main
+//
+// More text.
+package greet
+
+// Config configures greet
+type Config struct {
+	// Name to greet, e.g.
+	// embedme config.yaml
+	// The name is required.
+	Name string
+}
+	`) + "\n"
+
+	expected := strings.TrimSpace(`
+// Package greet greets.
+//
+// embedme examples/basic.go
+//
+//	package main
+//
+//	func main() {
+//		greet("embedme")
+//	}
+//
+// More text.
+package greet
+
+// Config configures greet
+type Config struct {
+	// Name to greet, e.g.
+	// embedme config.yaml
+	//
+	//	name: embedme
+	//
+	// The name is required.
+	Name string
+}
+	`) + "\n"
+
+	embedded, err := embedder.Embed([]byte(source), filepath.Join(workingDir, "greet.go"), "greet.go")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded source: %s", diff)
+	}
+
+	// embedding again must not change the source
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "greet.go"), "greet.go")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
+
+func TestEmbedGoDocCommentsSkipsStrings(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+	afero.WriteFile(fs, "/work/dir/other.go", []byte("package y\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	source := strings.Join([]string{
+		"package x",
+		"",
+		"const s = `",
+		"// embedme other.go",
+		"\tfoo",
+		"`",
+		"",
+		"func f() {",
+		"\t// embedme other.go",
+		"}",
+		"",
+	}, "\n")
+
+	embedded, err := embedder.Embed([]byte(source), filepath.Join(workingDir, "x.go"), "x.go")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, source); diff != "" {
+		t.Fatalf("expected strings and comments in functions to stay unchanged: %s", diff)
+	}
+}
+
+func TestEmbedPythonDocstrings(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/examples/greet.py", []byte("from greet import greet\n\ngreet('embedme')\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/examples/docstring.py", []byte("'''docstring'''\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	source := strings.TrimSpace(`
+TEMPLATE = """
+.. code-block:: python
+
+   not a docstring
+"""
+
+
+def greet(name):
+    """Greets someone.
+
+    .. embedme: examples/greet.py
+
+    Example::
+
+        greet("old")
+
+    """
+    print(f"Hello {name}")
+	`) + "\n"
+
+	expected := strings.TrimSpace(`
+TEMPLATE = """
+.. code-block:: python
+
+   not a docstring
+"""
+
+
+def greet(name):
+    """Greets someone.
+
+    .. embedme: examples/greet.py
+
+    Example::
+
+        from greet import greet
+
+        greet('embedme')
+
+    """
+    print(f"Hello {name}")
+	`) + "\n"
+
+	embedded, err := embedder.Embed([]byte(source), filepath.Join(workingDir, "greet.py"), "greet.py")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded source: %s", diff)
+	}
+
+	// embedding again must not change the source
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "greet.py"), "greet.py")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+
+	// code that ends the docstring is refused
+	source = "def f():\n    '''\n    .. code-block:: python\n       :embed: examples/docstring.py\n    '''\n"
+	if _, err := embedder.Embed([]byte(source), filepath.Join(workingDir, "f.py"), "f.py"); err == nil {
+		t.Fatalf("expected embedding the docstring quotes to fail")
+	}
+}
+
+// syntheticPython generates a Python module with a docstring for every function
+func syntheticPython(functions int) string {
+	var source strings.Builder
+	for i := 0; i < functions; i++ {
+		fmt.Fprintf(&source, "def function%d():\n", i)
+		fmt.Fprintf(&source, "    \"\"\"Function %d.\n\n    .. embedme: examples/function%d.py\n\n", i, i)
+		source.WriteString("    Example::\n\n        function()\n\n    \"\"\"\n")
+		source.WriteString("    return None\n\n\n")
+	}
+	return source.String()
+}
+
+func BenchmarkPythonDocstringCodeBlocks(b *testing.B) {
+	source := syntheticPython(1000)
+	b.SetBytes(int64(len(source)))
+	for i := 0; i < b.N; i++ {
+		PythonDocstringFormat{}.CodeBlocks(source)
+	}
+}
+
+// BenchmarkPythonDocstringLines compares looking up the lines of all docstrings
+// using a line index with splitting the source before every docstring
+func BenchmarkPythonDocstringLines(b *testing.B) {
+	source := syntheticPython(1000)
+	docstrings := pythonDocstrings(source)
+	b.Run("LineIndex", func(b *testing.B) {
+		b.SetBytes(int64(len(source)))
+		for i := 0; i < b.N; i++ {
+			index := internal.NewLineIndex(source)
+			for _, docstring := range docstrings {
+				index.Line(docstring.start)
+			}
+		}
+	})
+	b.Run("LineNumber", func(b *testing.B) {
+		b.SetBytes(int64(len(source)))
+		for i := 0; i < b.N; i++ {
+			for _, docstring := range docstrings {
+				internal.LineNumber(source, docstring.start)
+			}
+		}
+	})
+}
@@ -0,0 +1,372 @@
+package embedme
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+	"golang.org/x/exp/slices"
+)
+
+// DocumentFormat finds the code blocks of a document and replaces their code
+//
+// Formats are selected by the extension of a document or by name
+// (see Options.Format). Custom formats are added using RegisterDocumentFormat.
+type DocumentFormat interface {
+	// Name of the format
+	Name() string
+	// ParseDirective parses an embedme comment in a line of a document
+	ParseDirective(line string) (Directive, bool)
+	// CodeBlocks extracts the code blocks of a document
+	CodeBlocks(source string) []CodeBlock
+	// Replace returns the start and end offset of the source that is replaced
+	// with the embedded code of a block and the replacement
+	Replace(source string, block *CodeBlock, code string) (int, int, string)
+}
+
+// CalloutRenderer is implemented by document formats that render callouts
+//
+// Callouts are not stripped from code embedded into these formats.
+type CalloutRenderer interface {
+	RendersCallouts() bool
+}
+
+// rendersCallouts checks if a document format renders callouts
+func rendersCallouts(format DocumentFormat) bool {
+	renderer, ok := format.(CalloutRenderer)
+	return ok && renderer.RendersCallouts()
+}
+
+// CodeEscaper is implemented by document formats that escape embedded code
+type CodeEscaper interface {
+	// Escape escapes the lines embedded into a block or fails if they cannot be escaped
+	Escape(block *CodeBlock, lines []string) ([]string, error)
+}
+
+// DocumentContainer is implemented by document formats that contain nested documents
+//
+// The code blocks of the nested documents (e.g. the cells of a notebook)
+// are embedded using the format of each nested document.
+type DocumentContainer interface {
+	// Documents returns the nested documents of a document
+	Documents(source string) ([]NestedDocument, error)
+	// EncodeDocument encodes the embedded source of a nested document
+	EncodeDocument(source string, document *NestedDocument, embedded string) string
+}
+
+// NestedDocument is a document inside of another document
+type NestedDocument struct {
+	// Start and End are the offsets of the encoded document
+	Start int
+	End   int
+	// Source is the decoded source of the document
+	Source string
+	// Format is the format of the document
+	Format DocumentFormat
+}
+
+// Directive is an embedme comment that applies to the next code block
+type Directive struct {
+	// Command is the embed command (e.g. a path)
+	Command string
+	// IgnoreNext skips the next code block
+	IgnoreNext bool
+	// IgnoreStart skips the code blocks until the next IgnoreEnd
+	IgnoreStart bool
+	// IgnoreEnd ends a region of skipped code blocks
+	IgnoreEnd bool
+	// IgnoreFile skips all code blocks of the document
+	IgnoreFile bool
+}
+
+// update updates the pending directive if a line is a directive of the format
+func (d *Directive) update(format DocumentFormat, line string) bool {
+	directive, ok := format.ParseDirective(line)
+	if !ok {
+		return false
+	}
+	d.merge(directive)
+	return true
+}
+
+// merge merges a directive into the pending directive
+func (d *Directive) merge(directive Directive) {
+	if directive.IgnoreNext {
+		d.IgnoreNext = true
+	}
+	if directive.Command != "" {
+		d.Command = directive.Command
+	}
+}
+
+// parseDirective parses a directive using an ignore and a command expression
+//
+// The last group of the ignore expression is the kind of the ignore
+// comment (next, start, end or file).
+func parseDirective(line string, ignore *regexp.Regexp, command *regexp.Regexp) (Directive, bool) {
+	if match := ignore.FindStringSubmatch(line); match != nil {
+		kind := match[len(match)-1]
+		return Directive{
+			IgnoreNext:  kind == "next",
+			IgnoreStart: kind == "start",
+			IgnoreEnd:   kind == "end",
+			IgnoreFile:  kind == "file",
+		}, true
+	}
+	if match := command.FindStringSubmatch(line); match != nil {
+		return Directive{Command: match[1]}, true
+	}
+	return Directive{}, false
+}
+
+var (
+	// DocumentFormats are the registered document formats for each file extension
+	DocumentFormats = map[string]DocumentFormat{}
+)
+
+func init() {
+	RegisterDocumentFormat(MarkdownFormat{}, "md", "markdown")
+	RegisterDocumentFormat(ReStructuredTextFormat{}, "rst", "rest")
+	RegisterDocumentFormat(AsciiDocFormat{}, "adoc", "asciidoc", "asc")
+	RegisterDocumentFormat(OrgFormat{}, "org")
+	RegisterDocumentFormat(LaTeXFormat{}, "tex", "latex")
+	RegisterDocumentFormat(GoDocFormat{}, "go")
+	RegisterDocumentFormat(PythonDocstringFormat{}, "py")
+	RegisterDocumentFormat(NotebookFormat{}, "ipynb")
+}
+
+// RegisterDocumentFormat registers a document format for file extensions
+func RegisterDocumentFormat(format DocumentFormat, extensions ...string) {
+	for _, ext := range extensions {
+		DocumentFormats[strings.ToLower(strings.TrimPrefix(ext, "."))] = format
+	}
+}
+
+// FormatForPath returns the document format of a path
+//
+// Files of other languages with comments (e.g. YAML or Dockerfiles) use
+// the RegionFormat. Markdown is used for unknown file extensions.
+func FormatForPath(path string) DocumentFormat {
+	return formatForPath(path, Languages)
+}
+
+// formatForPath returns the document format of a path using the languages of a registry
+func formatForPath(path string, languages *LanguageRegistry) DocumentFormat {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format, ok := DocumentFormats[ext]; ok {
+		return withLanguages(format, languages)
+	}
+	lang := languages.languageForPath(path)
+	if lang == "" {
+		return MarkdownFormat{}
+	}
+	if language, ok := languages.Lookup(lang); ok && language.Name != "Text" && language.HasComments() {
+		return RegionFormat{Language: lang, Languages: languages}
+	}
+	return MarkdownFormat{}
+}
+
+// withLanguages returns a document format that looks up languages in a registry
+func withLanguages(format DocumentFormat, languages *LanguageRegistry) DocumentFormat {
+	switch format := format.(type) {
+	case RegionFormat:
+		format.Languages = languages
+		return format
+	case NotebookFormat:
+		format.Languages = languages
+		return format
+	}
+	return format
+}
+
+// FormatForName returns the document format with a name or for a file extension
+func FormatForName(name string) (DocumentFormat, error) {
+	name = strings.ToLower(name)
+	if format, ok := DocumentFormats[name]; ok {
+		return format, nil
+	}
+	var names []string
+	for _, format := range DocumentFormats {
+		if format.Name() == name {
+			return format, nil
+		}
+		names = append(names, format.Name())
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf(
+		"unknown document format %q (must be one of %v)",
+		name, slices.Compact(names),
+	)
+}
+
+var (
+	// sourceLanguages maps the source languages of document formats to language IDs
+	sourceLanguages = map[string]LanguageID{
+		"c++":         "cpp",
+		"c#":          "cs",
+		"csharp":      "cs",
+		"console":     "bash",
+		"crystal":     "cr",
+		"haskell":     "hs",
+		"javascript":  "js",
+		"objective-c": "objc",
+		"plantuml":    "puml",
+		"protobuf":    "proto",
+		"ruby":        "rb",
+		"typescript":  "ts",
+		"yml":         "yaml",
+	}
+)
+
+// sourceLanguage maps the (case insensitive) source language of a document format to a language ID
+func sourceLanguage(language string) LanguageID {
+	language = strings.ToLower(language)
+	if id, ok := sourceLanguages[language]; ok {
+		return id
+	}
+	return LanguageID(language)
+}
+
+// MarkdownFormat is the format of markdown documents
+type MarkdownFormat struct{}
+
+// Name ...
+func (MarkdownFormat) Name() string {
+	return "markdown"
+}
+
+// ParseDirective parses <!-- embedme path --> and <!-- embedme-ignore-... --> comments
+func (MarkdownFormat) ParseDirective(line string) (Directive, bool) {
+	return parseDirective(line, ignoreRegex, embedCommentRegex)
+}
+
+// CodeBlocks extracts the fenced code blocks of a document
+//
+// An embed comment that is not followed by a code block yields an empty block
+// without fences, which is created after the comment (see Replace).
+func (MarkdownFormat) CodeBlocks(source string) []CodeBlock {
+	return scanMarkdown(source, true)
+}
+
+// Replace lengthens the fences if the code contains a fence
+// and fills in the language of blocks without an info string
+//
+// Blocks without fences are created after their embed comment.
+func (MarkdownFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	if block.Fence == "" {
+		return block.Start, block.End, createMarkdownBlock(source, block, code)
+	}
+	fence := block.FenceFor(code)
+	fillInfo := block.Info == "" && block.Language != ""
+	if fence == block.Fence && !fillInfo {
+		return block.Start, block.End, code
+	}
+	closing := block.ClosingFence
+	if fence != block.Fence {
+		// lengthen the fences so that the embedded code cannot close the block
+		Info(log.Writer(), "Lengthening fence to %s\n", fence)
+		closing = fence
+	}
+	info := source[block.FenceStart+len(block.Fence) : block.Start]
+	if fillInfo {
+		info = string(block.Language) + strings.TrimLeft(info, " \t")
+	}
+	return block.FenceStart, block.End + len(block.ClosingFence), fence + info + code + closing
+}
+
+// createMarkdownBlock returns a new fenced code block for the code of a block without fences
+func createMarkdownBlock(source string, block *CodeBlock, code string) string {
+	if code == "" {
+		return ""
+	}
+	newline := internal.DetectNewline([]byte(source))
+	fence := (&CodeBlock{Fence: "```"}).FenceFor(code)
+	created := block.Indent + fence + string(block.Language) + newline + code + fence + newline
+	if !strings.HasSuffix(source[:block.Start], "\n") {
+		// the embed comment is in the last line
+		created = newline + created
+	}
+	Info(log.Writer(), "Creating code block after embed comment\n")
+	return created
+}
+
+// sourceLine is a line of a document
+type sourceLine struct {
+	// text of the line without the line ending
+	text string
+	// start is the offset of the line
+	start int
+	// end is the offset of the next line
+	end int
+}
+
+func (l sourceLine) blank() bool {
+	return strings.TrimSpace(l.text) == ""
+}
+
+// sourceLines splits a document into lines
+func sourceLines(source string) []sourceLine {
+	var lines []sourceLine
+	for offset := 0; offset < len(source); {
+		end := strings.IndexByte(source[offset:], '\n')
+		if end < 0 {
+			end = len(source)
+		} else {
+			end += offset + 1
+		}
+		text := strings.TrimSuffix(strings.TrimSuffix(source[offset:end], "\n"), "\r")
+		lines = append(lines, sourceLine{text: text, start: offset, end: end})
+		offset = end
+	}
+	return lines
+}
+
+// commonIndent returns the common leading whitespace of the non-blank lines
+func commonIndent(lines []sourceLine) string {
+	var texts []string
+	for _, line := range lines {
+		if !line.blank() {
+			texts = append(texts, line.text)
+		}
+	}
+	if len(texts) == 0 {
+		return ""
+	}
+	dedented := internal.Dedent(texts)
+	return texts[0][:len(texts[0])-len(dedented[0])]
+}
+
+// trimBlankLines removes the whitespace of blank lines but keeps their line endings
+func trimBlankLines(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = line[len(strings.TrimRight(line, "\r")):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// enclosedBlock creates the code block between an opening and a closing line
+//
+// Like fenced code blocks, the block is indented like the opening line and
+// its code ends at the closing marker after the indentation of the closing line.
+func enclosedBlock(source string, lines []sourceLine, open int, close int) CodeBlock {
+	indent := lines[open].text[:internal.IndentWidth(lines[open].text)]
+	end := lines[close].start + internal.IndentWidth(lines[close].text)
+	return CodeBlock{
+		FenceStart:   lines[open].start + len(indent),
+		Fence:        strings.TrimSpace(lines[open].text),
+		ClosingFence: strings.TrimSpace(lines[close].text),
+		Start:        lines[open].end,
+		End:          end,
+		StartLine:    open + 2,
+		EndLine:      close + 1,
+		Indent:       indent,
+		Code:         source[lines[open].end:end],
+	}
+}
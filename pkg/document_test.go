@@ -0,0 +1,157 @@
+package embedme
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestFormatForPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"README.md":         "markdown",
+		"docs/index.rst":    "rst",
+		"docs/guide.ADOC":   "asciidoc",
+		"notes.org":         "org",
+		"paper/paper.tex":   "latex",
+		"unknown.txt":       "markdown",
+		"without-extension": "markdown",
+	} {
+		if name := FormatForPath(path).Name(); name != expected {
+			t.Errorf("%s: expected format %q but got %q", path, expected, name)
+		}
+	}
+
+	for name, expected := range map[string]string{
+		"markdown": "markdown",
+		"md":       "markdown",
+		"RST":      "rst",
+		"adoc":     "asciidoc",
+	} {
+		format, err := FormatForName(name)
+		if err != nil {
+			t.Fatalf("%s: failed to find format: %v", name, err)
+		}
+		if format.Name() != expected {
+			t.Errorf("%s: expected format %q but got %q", name, expected, format.Name())
+		}
+	}
+	if _, err := FormatForName("docx"); err == nil {
+		t.Errorf("expected unknown format to fail")
+	}
+}
+
+func TestParseDirective(t *testing.T) {
+	for _, c := range []struct {
+		format   DocumentFormat
+		line     string
+		expected Directive
+		ok       bool
+	}{
+		{MarkdownFormat{}, "<!-- embedme main.go#L1-2 -->", Directive{Command: "main.go#L1-2"}, true},
+		{MarkdownFormat{}, "<!-- embedme-ignore-next -->", Directive{IgnoreNext: true}, true},
+		{MarkdownFormat{}, "<!-- comment -->", Directive{}, false},
+		{ReStructuredTextFormat{}, ".. embedme: main.go", Directive{Command: "main.go"}, true},
+		{ReStructuredTextFormat{}, ".. embedme-ignore-next", Directive{IgnoreNext: true}, true},
+		{AsciiDocFormat{}, "// embedme main.go", Directive{Command: "main.go"}, true},
+		{OrgFormat{}, "# embedme ignore-next", Directive{IgnoreNext: true}, true},
+		{LaTeXFormat{}, "% embedme main.go", Directive{Command: "main.go"}, true},
+		{LaTeXFormat{}, "%% comment", Directive{}, false},
+	} {
+		directive, ok := c.format.ParseDirective(c.line)
+		if ok != c.ok || directive != c.expected {
+			t.Errorf("%s: %q: expected %+v (%t) but got %+v (%t)", c.format.Name(), c.line, c.expected, c.ok, directive, ok)
+		}
+	}
+}
+
+var (
+	snippetStartRegex = regexp.MustCompile(`^BEGIN (\S+)$`)
+	snippetEndRegex   = regexp.MustCompile(`^END$`)
+)
+
+// snippetFormat is a custom format with snippets between BEGIN <path> and END lines
+type snippetFormat struct{}
+
+func (snippetFormat) Name() string {
+	return "snippet"
+}
+
+func (snippetFormat) ParseDirective(line string) (Directive, bool) {
+	if match := snippetStartRegex.FindStringSubmatch(line); match != nil {
+		return Directive{Command: match[1]}, true
+	}
+	return Directive{}, false
+}
+
+func (f snippetFormat) CodeBlocks(source string) []CodeBlock {
+	var blocks []CodeBlock
+	lines := sourceLines(source)
+	for i := 0; i < len(lines); i++ {
+		directive, ok := f.ParseDirective(lines[i].text)
+		if !ok {
+			continue
+		}
+		for end := i + 1; end < len(lines); end++ {
+			if snippetEndRegex.MatchString(lines[end].text) {
+				block := enclosedBlock(source, lines, i, end)
+				block.Language = languageForPath(directive.Command)
+				block.EmbedComment = directive.Command
+				blocks = append(blocks, block)
+				i = end
+				break
+			}
+		}
+	}
+	return blocks
+}
+
+func (snippetFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	return block.Start, block.End, code
+}
+
+func TestRegisterDocumentFormat(t *testing.T) {
+	RegisterDocumentFormat(snippetFormat{}, ".snippets")
+	defer delete(DocumentFormats, "snippets")
+
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+	afero.WriteFile(fs, "/work/dir/main.go", []byte("package main\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	document := "Snippets\nBEGIN main.go\nEND\n"
+	expected := "Snippets\nBEGIN main.go\npackage main\nEND\n"
+	embedded, err := embedder.Embed([]byte(document), filepath.Join(workingDir, "doc.snippets"), "doc.snippets")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	// the format can be configured independent of the extension
+	embedder.Options.Format = "snippet"
+	embedded, err = embedder.Embed([]byte(document), filepath.Join(workingDir, "doc.txt"), "doc.txt")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	embedder.Options.Format = "unknown"
+	if _, err := embedder.Embed([]byte(document), filepath.Join(workingDir, "doc.txt"), "doc.txt"); err == nil ||
+		!strings.Contains(err.Error(), "unknown document format") {
+		t.Fatalf("expected unknown format to fail but got %v", err)
+	}
+}
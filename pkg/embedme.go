@@ -5,18 +5,17 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"unicode"
 
 	"github.com/fatih/color"
 	"github.com/romnn/embedme/internal"
+	"github.com/romnn/embedme/pkg/commands"
 	"github.com/romnn/embedme/pkg/fs"
 	"github.com/spf13/afero"
 )
 
 var (
-	backtickRegex = regexp.MustCompile("^```")
 	// Magenta ...
 	Magenta = color.New(color.FgMagenta).FprintfFunc()
 	// Success ...
@@ -122,6 +121,7 @@ func (e *Embedder) embedBlock(
 	absPath string,
 	relPath string,
 	block *CodeBlock,
+	format DocumentFormat,
 	newline string,
 ) (string, error) {
 	if !filepath.IsAbs(absPath) {
@@ -139,14 +139,18 @@ func (e *Embedder) embedBlock(
 	}
 	Info(log.Writer(), logPrefix+"\n")
 
-	if block.Language == "" {
+	if block.Language == "" && block.EmbedComment == "" {
 		Info(log.Writer(), "No code extension detected, skipping ...\n")
 		return block.Code, nil
 	}
 
-	if _, err := block.CommentType(); err != nil {
-		Warning(log.Writer(), err.Error()+"\n")
-		return block.Code, nil
+	var lang *Language
+	if block.Language != "" {
+		var err error
+		if lang, err = e.Options.language(block.Language); err != nil {
+			Warning(log.Writer(), err.Error()+"\n")
+			return block.Code, nil
+		}
 	}
 
 	commandComment, command, err := block.EmbedCommand(e.FS, &e.Options)
@@ -168,6 +172,18 @@ func (e *Embedder) embedBlock(
 	if err != nil {
 		return block.Code, err
 	}
+	if e.Options.StripCallouts && !rendersCallouts(format) {
+		lines = stripCallouts(lines)
+	}
+	for _, normalizer := range e.Options.Normalizers {
+		lines = normalizer.Normalize(lines)
+	}
+	lang = e.blockLanguage(block, lang, command, lines)
+	if escaper, ok := format.(CodeEscaper); ok {
+		if lines, err = escaper.Escape(block, lines); err != nil {
+			return block.Code, err
+		}
+	}
 
 	output := strings.Join(lines, newline)
 	output = strings.TrimRightFunc(output, func(r rune) bool {
@@ -175,28 +191,18 @@ func (e *Embedder) embedBlock(
 	})
 	output += newline
 
-	if output == block.Code {
+	if output == block.Content() {
 		color.White("No changes required, already up to date")
 		return block.Code, nil
 	}
 
-	if len(backtickRegex.FindAllIndex([]byte(output), 1)) > 0 {
-		// invalid
-		return block.Code, fmt.Errorf(
-			"refusing to embed:\n%s\n as it contains a code block",
-			strings.Join(internal.PreviewLines(lines, 3), newline),
-		)
-	}
-
 	var replacement string
 	// replacement += "```"
 	// replacement += string(block.Language)
 	// replacement += newline
 	if !(e.Options.StripEmbedComment || block.EmbedComment != "") {
-		// comment for langugage
-		replacement += block.Comment() + " "
-		// embed command
-		replacement += strings.TrimSpace(commandComment)
+		// embed command in a comment of the langugage
+		replacement += lang.Comment(strings.TrimSpace(commandComment))
 		replacement += newline
 		replacement += newline
 	}
@@ -221,6 +227,70 @@ func (e *Embedder) embedBlock(
 	return replacement, nil
 }
 
+// blockLanguage compares the language of a block with the language of the embedded code
+//
+// Blocks without a language get the inferred language.
+func (e *Embedder) blockLanguage(
+	block *CodeBlock,
+	lang *Language,
+	command commands.Command,
+	lines []string,
+) *Language {
+	inferred := e.inferLanguage(command, lines)
+	switch {
+	case inferred == nil:
+	case lang == nil:
+		Info(log.Writer(), "Inferred language %s\n", inferred.ID())
+		block.Language = inferred.ID()
+		return inferred
+	case lang.Name != "Text" && !lang.compatibleWith(inferred):
+		Warning(
+			log.Writer(),
+			"Block language %q does not match the embedded %s code\n",
+			block.Language, inferred.Name,
+		)
+	}
+	return lang
+}
+
+// inferLanguage infers the language of embedded code
+//
+// The language is given by the path of an embedded file, the command
+// or the shebang of the code.
+func (e *Embedder) inferLanguage(command commands.Command, lines []string) *Language {
+	var id LanguageID
+	switch cmd := command.(type) {
+	case *commands.EmbedFileCommand:
+		id = e.Options.registry().languageForPath(cmd.Path)
+	case commands.LanguageHinter:
+		id = LanguageID(cmd.Language())
+	}
+	if id != "" {
+		if lang, err := e.Options.language(id); err == nil {
+			return lang
+		}
+	}
+	if len(lines) > 0 {
+		if lang, ok := e.Options.shebangLanguage(lines[0]); ok {
+			return lang
+		}
+	}
+	return nil
+}
+
+// format returns the configured document format or the format of a path
+func (e *Embedder) format(path string) (DocumentFormat, error) {
+	languages := e.Options.registry()
+	if e.Options.Format != "" {
+		format, err := FormatForName(e.Options.Format)
+		if err != nil {
+			return nil, err
+		}
+		return withLanguages(format, languages), nil
+	}
+	return formatForPath(path, languages), nil
+}
+
 // Embed embeds a document
 func (e *Embedder) Embed(
 	markdown []byte,
@@ -229,49 +299,103 @@ func (e *Embedder) Embed(
 ) (string, error) {
 	color.Magenta("Analysing %s ...", relPath)
 
+	if len(e.Options.Overrides) > 0 {
+		options, err := e.Options.forPath(absPath)
+		if err != nil {
+			return "", err
+		}
+		embedder := *e
+		embedder.Options = options
+		e = &embedder
+	}
+
+	format, err := e.format(absPath)
+	if err != nil {
+		return "", err
+	}
+	return e.embedDocument(string(markdown), format, absPath, relPath)
+}
+
+// embedDocument embeds the code blocks of a document
+func (e *Embedder) embedDocument(
+	source string,
+	format DocumentFormat,
+	absPath string,
+	relPath string,
+) (string, error) {
+	if container, ok := format.(DocumentContainer); ok {
+		return e.embedNestedDocuments(source, container, absPath, relPath)
+	}
+
 	var partials []string
 	previousEnd := 0
-	newline := internal.DetectNewline(markdown)
-
-	blocks := ExtractCodeBlocks(string(markdown))
+	newline := internal.DetectNewline([]byte(source))
+	blocks := format.CodeBlocks(source)
+	lines := sourceLines(source)
+	regions, ignoreFile := ignoreRegions(lines, format, blocks)
+	if ignoreFile {
+		Info(log.Writer(), "Ignore file comment detected, skipping ...\n")
+		return source, nil
+	}
 
 	for _, block := range blocks {
-		// log.Printf("\n%+v\n", block)
-
-		// add the partial here
-		between := string(markdown)[previousEnd:block.Start]
-		// log.Printf("PREV:\n%q\n", between)
-		partials = append(partials, between)
-
-		// newStartLine := 0
-		// if e.Options.DryRun || e.Options.Stdout || e.Options.Verify {
-		// 	newStartLine = block.StartLine
-		// } else {
-		// 	currentLines := internal.Lines(strings.Join(partials, newline))
-		// 	newStartLine = len(currentLines) - 1
-		// 	// log.Printf("block %d starts in line %d\n", bi, newStartLine)
-		// }
-
+		if reason := e.skipReason(lines, format, &block, regions); reason != "" {
+			Info(log.Writer(), "%s, skipping ...\n", reason)
+			continue
+		}
 		embedded, err := e.embedBlock(
 			absPath,
 			relPath,
 			&block,
+			format,
 			newline,
 		)
 		if err != nil {
 			return "", err
 		}
 
-		partials = append(partials, embedded)
-		previousEnd = block.End
-
-		// log.Printf("EMBEDDED:\n%q\n", embedded)
+		start, end, replacement := format.Replace(source, &block, embedded)
+		partials = append(partials, source[previousEnd:start], replacement)
+		previousEnd = end
 	}
 
 	// add the final partial here
-	between := string(markdown)[previousEnd:]
+	between := source[previousEnd:]
 	partials = append(partials, between)
 
 	final := strings.Join(partials, "")
 	return final, nil
 }
+
+// embedNestedDocuments embeds the nested documents of a document
+//
+// Only nested documents that changed are encoded again.
+func (e *Embedder) embedNestedDocuments(
+	source string,
+	container DocumentContainer,
+	absPath string,
+	relPath string,
+) (string, error) {
+	documents, err := container.Documents(source)
+	if err != nil {
+		return "", err
+	}
+
+	var partials []string
+	previousEnd := 0
+	for i := range documents {
+		document := &documents[i]
+		embedded, err := e.embedDocument(document.Source, document.Format, absPath, relPath)
+		if err != nil {
+			return "", err
+		}
+		if embedded == document.Source {
+			continue
+		}
+		encoded := container.EncodeDocument(source, document, embedded)
+		partials = append(partials, source[previousEnd:document.Start], encoded)
+		previousEnd = document.End
+	}
+	partials = append(partials, source[previousEnd:])
+	return strings.Join(partials, ""), nil
+}
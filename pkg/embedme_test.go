@@ -1,6 +1,7 @@
 package embedme
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -14,16 +15,25 @@ func pretty(m interface{}) string {
 	return pp.Sprint(m)
 }
 
+func Example_basic() {
+	fence := strings.Repeat("`", 3)
+	markdown := "# Readme\n\n" + fence + "go\n// main.go\n" + fence + "\n"
+	for _, block := range ExtractCodeBlocks(markdown) {
+		fmt.Printf("%s block in line %d: %q\n", block.Language, block.StartLine, block.Code)
+	}
+	// Output: go block in line 4: "// main.go\n"
+}
+
 func TestFirstCommentHash(t *testing.T) {
 	for _, c := range []struct {
 		description string
 		source      string
-		language    []LanguageID
+		language    LanguageID
 		expected    string
 	}{
 		{
 			description: "single valid Python comment",
-			language:    Python,
+			language:    "py",
 			source: `
 
 # valid Python comment
@@ -35,7 +45,7 @@ def test():
 		},
 		{
 			description: "single invalid Python comment",
-			language:    Python,
+			language:    "py",
 			source: `
 
 // invalid Python comment
@@ -47,7 +57,7 @@ def test():
 		},
 		{
 			description: "2 Python comments: valid then invalid",
-			language:    Python,
+			language:    "py",
 			source: `
 
   # valid Python comment
@@ -60,7 +70,7 @@ def test():
 		},
 		{
 			description: "2 Python comments: invalid then valid",
-			language:    Python,
+			language:    "py",
 			source: `
 
 // invalid Python comment
@@ -73,7 +83,7 @@ def test():
 		},
 		{
 			description: "2 valid Python comments",
-			language:    Python,
+			language:    "py",
 			source: `
   # valid 1
   # valid 2
@@ -84,12 +94,11 @@ def test():
 			expected: " valid 1",
 		},
 	} {
-		lang := c.language[0]
-		commentTyp, ok := CommentForLanguage[lang]
+		lang, ok := Languages.Lookup(c.language)
 		if !ok {
-			t.Fatalf("Unsupported file extension %q", lang)
+			t.Fatalf("Unsupported file extension %q", c.language)
 		}
-		comment, _ := FirstComment(c.source, commentTyp)
+		comment, _ := FirstComment(c.source, lang)
 
 		equal := cmp.Equal(comment, c.expected)
 		diff := cmp.Diff(comment, c.expected)
@@ -124,18 +133,24 @@ block2
       `,
 			expected: []CodeBlock{
 				{
-					Code:      "block1\n",
-					Start:     31,
-					End:       38,
-					StartLine: 5,
-					EndLine:   6,
+					FenceStart:   27,
+					Fence:        "```",
+					ClosingFence: "```",
+					Code:         "block1\n",
+					Start:        31,
+					End:          38,
+					StartLine:    5,
+					EndLine:      6,
 				},
 				{
-					Code:      "block2\n",
-					Start:     47,
-					End:       54,
-					StartLine: 9,
-					EndLine:   10,
+					FenceStart:   43,
+					Fence:        "```",
+					ClosingFence: "```",
+					Code:         "block2\n",
+					Start:        47,
+					End:          54,
+					StartLine:    9,
+					EndLine:      10,
 				},
 			},
 		},
@@ -155,20 +170,28 @@ block2
       `,
 			expected: []CodeBlock{
 				{
-					Code:      "block1\n",
-					Language:  "python",
-					Start:     37,
-					End:       44,
-					StartLine: 5,
-					EndLine:   6,
+					FenceStart:   27,
+					Fence:        "```",
+					ClosingFence: "```",
+					Code:         "block1\n",
+					Language:     "python",
+					Info:         "python",
+					Start:        37,
+					End:          44,
+					StartLine:    5,
+					EndLine:      6,
 				},
 				{
-					Code:      "block2\n",
-					Language:  "python",
-					Start:     59,
-					End:       66,
-					StartLine: 9,
-					EndLine:   10,
+					FenceStart:   49,
+					Fence:        "```",
+					ClosingFence: "```",
+					Code:         "block2\n",
+					Language:     "python",
+					Info:         "python",
+					Start:        59,
+					End:          66,
+					StartLine:    9,
+					EndLine:      10,
 				},
 			},
 		},
@@ -188,20 +211,28 @@ This is a regular readme
       `,
 			expected: []CodeBlock{
 				{
-					Code:      "// bad comment\n",
-					Language:  "python",
-					Start:     37,
-					End:       52,
-					StartLine: 5,
-					EndLine:   6,
+					FenceStart:   27,
+					Fence:        "```",
+					ClosingFence: "```",
+					Code:         "// bad comment\n",
+					Language:     "python",
+					Info:         "python",
+					Start:        37,
+					End:          52,
+					StartLine:    5,
+					EndLine:      6,
 				},
 				{
-					Code:      "# good comment\n",
-					Language:  "python",
-					Start:     67,
-					End:       82,
-					StartLine: 9,
-					EndLine:   10,
+					FenceStart:   57,
+					Fence:        "```",
+					ClosingFence: "```",
+					Code:         "# good comment\n",
+					Language:     "python",
+					Info:         "python",
+					Start:        67,
+					End:          82,
+					StartLine:    9,
+					EndLine:      10,
 				},
 			},
 		},
@@ -375,3 +406,542 @@ func TestEmbedCommands(t *testing.T) {
 	afero.WriteFile(appFS, "src/a/b", []byte("file b"), 0644)
 	afero.WriteFile(appFS, "src/c", []byte("file c"), 0644)
 }
+
+func TestEmbedGoStruct(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/config/config.go", []byte(strings.TrimSpace(`
+package config
+
+// Config is the configuration
+type Config struct {
+	// Name of the service
+	Name string `+"`"+`json:"name" yaml:"name" env:"NAME"`+"`"+`
+	Server Server `+"`"+`json:"server"`+"`"+` // Server | settings
+	Limits struct {
+		// Maximum number of requests
+		Requests int
+	}
+	private bool
+}
+
+// Server configures the server
+type Server struct {
+	// Port to listen on
+	Port int `+"`"+`yaml:"port"`+"`"+`
+}
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+` + "```" + `
+block without language
+` + "```" + `
+
+<!-- embedme go-struct config/config.go#Config recursive -->
+` + "```" + `md
+` + "```" + `
+	`)
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+
+	expected := strings.TrimSpace(`
+` + "```" + `
+block without language
+` + "```" + `
+
+<!-- embedme go-struct config/config.go#Config recursive -->
+` + "```" + `md
+| Field | Type | Tags | Description |
+| ----- | ---- | ---- | ----------- |
+| ` + "`Name`" + ` | ` + "`string`" + ` | ` + "`json:\"name\"` `yaml:\"name\"` `env:\"NAME\"`" + ` | Name of the service |
+| ` + "`Server`" + ` | ` + "`Server`" + ` | ` + "`json:\"server\"`" + ` | Server \| settings |
+| ` + "`Server.Port`" + ` | ` + "`int`" + ` | ` + "`yaml:\"port\"`" + ` | Port to listen on |
+| ` + "`Limits`" + ` | ` + "`struct`" + ` |  |  |
+| ` + "`Limits.Requests`" + ` | ` + "`int`" + ` |  | Maximum number of requests |
+` + "```" + `
+	`)
+
+	equal := cmp.Equal(embedded, expected)
+	diff := cmp.Diff(embedded, expected)
+	if !equal {
+		t.Logf("expected: %s", expected)
+		t.Fatalf("unexpected embedded struct table: %s", diff)
+	}
+}
+
+func TestEmbedGoDoc(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/greet/greet.go", []byte(strings.TrimSpace(`
+// Package greet greets people
+package greet
+
+import "fmt"
+
+// Greeter greets people
+type Greeter struct {
+	// Name of the greeter
+	Name string
+	secret string
+}
+
+// NewGreeter creates a new greeter
+func NewGreeter(name string) Greeter {
+	return Greeter{Name: name}
+}
+
+// Greet greets a person
+func (g Greeter) Greet(name string) {
+	fmt.Printf("%s greets %s\n", g.Name, name)
+}
+	`)), 0644)
+	afero.WriteFile(fs, "/work/dir/greet/greet_test.go", []byte(strings.TrimSpace(`
+package greet_test
+
+func ExampleGreeter_Greet() {
+	g := greet.NewGreeter("embedme")
+	g.Greet("you")
+	// Output: embedme greets you
+}
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+<!-- embedme godoc greet -->
+` + "```" + `go
+` + "```" + `
+
+<!-- embedme godoc greet#Greeter methods examples -->
+` + "```" + `go
+` + "```" + `
+	`)
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+
+	expected := strings.TrimSpace(`
+<!-- embedme godoc greet -->
+` + "```" + `go
+package greet
+
+// Package greet greets people
+
+type Greeter struct{ ... }
+    func NewGreeter(name string) Greeter
+` + "```" + `
+
+<!-- embedme godoc greet#Greeter methods examples -->
+` + "```" + `go
+// Greeter greets people
+type Greeter struct {
+	// Name of the greeter
+	Name string
+	// contains filtered or unexported fields
+}
+
+// NewGreeter creates a new greeter
+func NewGreeter(name string) Greeter
+
+// Greet greets a person
+func (g Greeter) Greet(name string)
+
+// Example:
+g := greet.NewGreeter("embedme")
+g.Greet("you")
+
+// Output:
+// embedme greets you
+` + "```" + `
+	`)
+
+	equal := cmp.Equal(embedded, expected)
+	diff := cmp.Diff(embedded, expected)
+	if !equal {
+		t.Logf("expected: %s", expected)
+		t.Fatalf("unexpected embedded documentation: %s", diff)
+	}
+}
+
+func TestEmbedGoExample(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/greet/greet_test.go", []byte(strings.TrimSpace(`
+package greet
+
+import "fmt"
+
+func Example_greet() {
+	// greet someone
+	for _, name := range []string{"you", "me"} {
+		fmt.Printf("Hello %s\n", name)
+	}
+	// Output:
+	// Hello you
+	// Hello me
+}
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+` + "```" + `go
+// greet/greet_test.go#Example_greet
+` + "```" + `
+
+<!-- embedme greet/greet_test.go#output:Example_greet -->
+` + "```" + `text
+` + "```" + `
+	`)
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+
+	expected := strings.TrimSpace(`
+` + "```" + `go
+// greet/greet_test.go#Example_greet
+
+// greet someone
+for _, name := range []string{"you", "me"} {
+	fmt.Printf("Hello %s\n", name)
+}
+` + "```" + `
+
+<!-- embedme greet/greet_test.go#output:Example_greet -->
+` + "```" + `text
+Hello you
+Hello me
+` + "```" + `
+	`)
+
+	equal := cmp.Equal(embedded, expected)
+	diff := cmp.Diff(embedded, expected)
+	if !equal {
+		t.Logf("expected: %s", expected)
+		t.Fatalf("unexpected embedded example: %s", diff)
+	}
+}
+
+func TestEmbedGoOutline(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/greet/greet.go", []byte(strings.TrimSpace(`
+package greet
+
+import "fmt"
+
+// Greet greets a person
+func Greet(name string) {
+	fmt.Printf("Hello %s\n", name)
+}
+
+func (g *Greeter) Greet(name string) {
+	fmt.Printf("%s greets %s\n", g.Name, name)
+}
+
+// Greeter greets people
+type Greeter struct {
+	// Name of the greeter
+	Name string
+}
+
+func (l Loud) Shout() {}
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+<!-- embedme greet/greet.go#outline -->
+` + "```" + `go
+` + "```" + `
+	`)
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+
+	expected := strings.TrimSpace(`
+<!-- embedme greet/greet.go#outline -->
+` + "```" + `go
+package greet
+
+type Greeter struct {
+	Name string
+}
+func (g *Greeter) Greet(name string) { ... }
+
+func (l Loud) Shout() { ... }
+
+func Greet(name string) { ... }
+` + "```" + `
+	`)
+
+	equal := cmp.Equal(embedded, expected)
+	diff := cmp.Diff(embedded, expected)
+	if !equal {
+		t.Logf("expected: %s", expected)
+		t.Fatalf("unexpected outline: %s", diff)
+	}
+}
+
+func TestEmbedLengthensFences(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	fence := "```"
+	afero.WriteFile(fs, "/work/dir/docs/usage.md", []byte(
+		"# Usage\n\n"+fence+"sh\nmake build\n"+fence+"\n",
+	), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := "" +
+		"<!-- embedme docs/usage.md -->\n" +
+		fence + "md\n" +
+		fence + "\n\n" +
+		"<!-- embedme docs/usage.md -->\n" +
+		"~~~md\n" +
+		"~~~~~\n"
+	expected := "" +
+		"<!-- embedme docs/usage.md -->\n" +
+		"````md\n" +
+		"# Usage\n\n" + fence + "sh\nmake build\n" + fence + "\n" +
+		"````\n\n" +
+		"<!-- embedme docs/usage.md -->\n" +
+		"~~~md\n" +
+		"# Usage\n\n" + fence + "sh\nmake build\n" + fence + "\n" +
+		"~~~~~\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded markdown: %s", diff)
+	}
+
+	// embedding again must not change the document
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
+
+func TestEmbedInfoStringDirectives(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/main.go", []byte(strings.TrimSpace(`
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	fence := "```"
+	readme := "" +
+		fence + "go title=\"main.go\" file=main.go lines=4-7 {1,3}\n" +
+		fence + "\n\n" +
+		fence + "go {embed=\"main.go#L2-3\"}\n" +
+		"old\n" +
+		fence + "\n"
+	expected := "" +
+		fence + "go title=\"main.go\" file=main.go lines=4-7 {1,3}\n" +
+		"func main() {\n\tfmt.Println(\"hello\")\n}\n" +
+		fence + "\n\n" +
+		fence + "go {embed=\"main.go#L2-3\"}\n" +
+		"import \"fmt\"\n" +
+		fence + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded markdown: %s", diff)
+	}
+}
+
+func TestEmbedInfersLanguage(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/main.go", []byte("package main\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/scripts/deploy", []byte("#!/usr/bin/env python3\nprint(\"deploy\")\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/notes", []byte("some notes\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	fence := "```"
+	readme := "" +
+		"<!-- embedme main.go -->\n" +
+		fence + "\n" +
+		fence + "\n\n" +
+		"<!-- embedme scripts/deploy -->\n" +
+		"~~~~\n" +
+		"~~~~\n\n" +
+		"<!-- embedme notes -->\n" +
+		fence + "\n" +
+		fence + "\n\n" +
+		"<!-- embedme main.go -->\n" +
+		fence + "python\n" +
+		fence + "\n"
+	expected := "" +
+		"<!-- embedme main.go -->\n" +
+		fence + "go\n" +
+		"package main\n" +
+		fence + "\n\n" +
+		"<!-- embedme scripts/deploy -->\n" +
+		"~~~~py\n" +
+		"#!/usr/bin/env python3\nprint(\"deploy\")\n" +
+		"~~~~\n\n" +
+		"<!-- embedme notes -->\n" +
+		fence + "\n" +
+		"some notes\n" +
+		fence + "\n\n" +
+		"<!-- embedme main.go -->\n" +
+		fence + "python\n" +
+		"package main\n" +
+		fence + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded markdown: %s", diff)
+	}
+
+	// embedding again must not change the document
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
+
+func TestEmbedCreatesMissingBlocks(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/main.go", []byte("package main\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/config.yaml", []byte("debug: true\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	fence := "```"
+	readme := "" +
+		"# Usage\n\n" +
+		"<!-- embedme main.go -->\n\n" +
+		"Some text.\n\n" +
+		"- configure\n\n" +
+		"  <!-- embedme config.yaml -->\n" +
+		"- run\n\n" +
+		"<!-- embedme-ignore-next -->\n" +
+		"<!-- embedme main.go -->\n\n" +
+		"<!-- embedme config.yaml -->"
+	expected := "" +
+		"# Usage\n\n" +
+		"<!-- embedme main.go -->\n" +
+		fence + "go\n" +
+		"package main\n" +
+		fence + "\n\n" +
+		"Some text.\n\n" +
+		"- configure\n\n" +
+		"  <!-- embedme config.yaml -->\n" +
+		"  " + fence + "yaml\n" +
+		"  debug: true\n" +
+		"  " + fence + "\n" +
+		"- run\n\n" +
+		"<!-- embedme-ignore-next -->\n" +
+		"<!-- embedme main.go -->\n\n" +
+		"<!-- embedme config.yaml -->\n" +
+		fence + "yaml\n" +
+		"debug: true\n" +
+		fence + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded markdown: %s", diff)
+	}
+
+	// embedding again updates the created blocks in place
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "readme.md"), "readme.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
@@ -1,48 +1,512 @@
 package fs
 
 import (
+	"fmt"
 	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/spf13/afero"
 )
 
-var replaces = regexp.MustCompile(`(\.)|(\*\*\/)|(\*)|([^\/\*]+)|(\/)`)
-
-func toRegexp(pattern string) string {
-	pat := replaces.ReplaceAllStringFunc(pattern, func(s string) string {
-		switch s {
-		case "/":
-			return "\\/"
-		case ".":
-			return "\\."
-		case "**/":
-			return ".*"
-		case "*":
-			return "[^/]*"
+// Globber finds the files that match glob patterns
+type Globber struct {
+	// Ignore skips files and prunes directories while walking
+	//
+	// The path is relative to the root of the file system and uses slashes.
+	// Directories that are part of the literal prefix of a pattern are never ignored.
+	Ignore func(path string, dir bool) bool
+}
+
+var (
+	// IgnoredDirs are the directories that are pruned by default
+	IgnoredDirs = []string{".git", ".hg", ".svn", "node_modules"}
+)
+
+// DefaultIgnore ignores version control and dependency directories (see IgnoredDirs)
+func DefaultIgnore(path string, dir bool) bool {
+	if !dir {
+		return false
+	}
+	name := filepath.Base(path)
+	for _, ignored := range IgnoredDirs {
+		if name == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// Glob returns the sorted list of files matching the patterns
+//
+// See Globber.Glob for the pattern syntax. Version control and dependency
+// directories are pruned (see DefaultIgnore).
+func Glob(fs afero.Fs, patterns ...string) ([]string, error) {
+	return Globber{Ignore: DefaultIgnore}.Glob(fs, patterns...)
+}
+
+// Glob returns the sorted list of files matching the patterns
+//
+// Patterns are relative to the root of the file system and use slashes:
+//   - * matches any sequence of characters except /
+//   - ** as a path segment matches any number of directories
+//   - ? matches a single character except /
+//   - [abc], [a-z] and [!abc] match a character of a class
+//   - {a,b} matches one of the comma separated alternatives
+//   - \ escapes the next character
+//
+// Files that match a negated pattern (e.g. !**/vendor/**) are excluded.
+// Only negated patterns match all files that are not excluded.
+//
+// Only the directories below the literal prefix of a pattern (e.g. docs for
+// docs/**/*.md) are walked, once for all patterns with the same prefix.
+// Directories that cannot contain matches or that are excluded by a negated
+// pattern ending with ** are pruned.
+// Symbolic links to directories are followed unless they form a cycle.
+func (g Globber) Glob(fs afero.Fs, patterns ...string) ([]string, error) {
+	var include, exclude []globPattern
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		compiled, err := compileGlob(strings.TrimPrefix(pattern, "!"))
+		if err != nil {
+			return nil, err
+		}
+		if negated {
+			exclude = append(exclude, compiled...)
+		} else {
+			include = append(include, compiled...)
+		}
+	}
+	if len(include) == 0 && len(exclude) > 0 {
+		include, _ = compileGlob("**")
+	}
+
+	matches := make(map[string]bool)
+	for _, patterns := range groupByPrefix(include) {
+		w := globWalker{fs: fs, globber: g, patterns: patterns, exclude: exclude, matches: matches}
+		if err := w.walk(); err != nil {
+			return nil, err
+		}
+	}
+
+	files := make([]string, 0, len(matches))
+	for file := range matches {
+		files = append(files, filepath.FromSlash(file))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Match checks if a path (using slashes) matches a glob pattern
+func Match(pattern string, path string) (bool, error) {
+	compiled, err := compileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, alternative := range compiled {
+		if alternative.match(path) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// globSegment is a path segment of a glob pattern
+type globSegment struct {
+	// doubleStar matches any number of path segments
+	doubleStar bool
+	// literal is the unescaped segment if it contains no wildcards
+	literal string
+	re      *regexp.Regexp
+}
+
+func (s globSegment) match(name string) bool {
+	if s.re == nil {
+		return s.literal == name
+	}
+	return s.re.MatchString(name)
+}
+
+// globPattern is a glob pattern without alternatives
+type globPattern struct {
+	segments []globSegment
+}
+
+// compileGlob compiles a pattern into one pattern for each alternative
+func compileGlob(pattern string) ([]globPattern, error) {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty glob pattern")
+	}
+	alternatives, err := expandBraces(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+	}
+	var compiled []globPattern
+	for _, alternative := range alternatives {
+		compiledAlternative, err := compilePattern(alternative)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+		compiled = append(compiled, compiledAlternative)
+	}
+	return compiled, nil
+}
+
+// compilePattern compiles a pattern without alternatives
+func compilePattern(pattern string) (globPattern, error) {
+	var segments []globSegment
+	for _, segment := range splitSegments(pattern) {
+		if segment == "" {
+			continue
+		}
+		compiled, err := compileSegment(segment)
+		if err != nil {
+			return globPattern{}, err
+		}
+		segments = append(segments, compiled)
+	}
+	if len(segments) == 0 {
+		return globPattern{}, fmt.Errorf("pattern has no path segments")
+	}
+	return globPattern{segments: segments}, nil
+}
+
+// expandBraces expands the {a,b} alternatives of a pattern
+func expandBraces(pattern string) ([]string, error) {
+	open, depth := -1, 0
+	var commas []int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			if end := classEnd(pattern, i); end > 0 {
+				i = end
+			}
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				return nil, fmt.Errorf("unmatched }")
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			prefix, suffix := pattern[:open], pattern[i+1:]
+			bounds := append(append([]int{open}, commas...), i)
+			var expanded []string
+			for j := 0; j < len(bounds)-1; j++ {
+				alternatives, err := expandBraces(prefix + pattern[bounds[j]+1:bounds[j+1]] + suffix)
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, alternatives...)
+			}
+			return expanded, nil
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unmatched {")
+	}
+	return []string{pattern}, nil
+}
+
+// splitSegments splits a pattern at slashes that are not escaped or in a character class
+func splitSegments(pattern string) []string {
+	var segments []string
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			if end := classEnd(pattern, i); end > 0 {
+				i = end
+			}
+		case '/':
+			segments = append(segments, pattern[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
+// classEnd returns the index of the ] that closes the character class at start or -1
+func classEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		// a leading ] is part of the class
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// compileSegment compiles a path segment of a pattern
+func compileSegment(segment string) (globSegment, error) {
+	if segment == "**" {
+		return globSegment{doubleStar: true}, nil
+	}
+	var expr, literal strings.Builder
+	wildcard := false
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch c {
+		case '\\':
+			if i+1 < len(segment) {
+				i++
+				c = segment[i]
+			}
+			literal.WriteByte(c)
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		case '*':
+			for i+1 < len(segment) && segment[i+1] == '*' {
+				i++
+			}
+			wildcard = true
+			expr.WriteString(`[^/]*`)
+		case '?':
+			wildcard = true
+			expr.WriteString(`[^/]`)
+		case '[':
+			end := classEnd(segment, i)
+			if end < 0 {
+				literal.WriteByte(c)
+				expr.WriteString(`\[`)
+				continue
+			}
+			wildcard = true
+			class := segment[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i = end
 		default:
-			return s
+			literal.WriteByte(c)
+			expr.WriteString(regexp.QuoteMeta(string(c)))
 		}
-	})
-	return "^" + pat + "$"
+	}
+	if !wildcard {
+		return globSegment{literal: literal.String()}, nil
+	}
+	re, err := regexp.Compile("^" + expr.String() + "$")
+	if err != nil {
+		return globSegment{}, err
+	}
+	return globSegment{re: re}, nil
 }
 
-// Glob returns a list of files matching the pattern.
-// The pattern can include **/ to match any number of directories.
-func Glob(fs afero.Fs, pattern string) ([]string, error) {
-	files := []string{}
+// prefix returns the directory of the leading literal segments of a pattern
+func (p globPattern) prefix() []string {
+	var prefix []string
+	for _, segment := range p.segments[:len(p.segments)-1] {
+		if segment.doubleStar || segment.re != nil {
+			break
+		}
+		prefix = append(prefix, segment.literal)
+	}
+	return prefix
+}
+
+// groupByPrefix groups the patterns with the same literal prefix, which are matched in a single walk
+func groupByPrefix(patterns []globPattern) [][]globPattern {
+	var groups [][]globPattern
+	index := make(map[string]int)
+	for _, pattern := range patterns {
+		prefix := path.Join(pattern.prefix()...)
+		i, ok := index[prefix]
+		if !ok {
+			i = len(groups)
+			index[prefix] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], pattern)
+	}
+	return groups
+}
+
+func (p globPattern) match(path string) bool {
+	return matchSegments(p.segments, strings.Split(path, "/"), false)
+}
+
+// mayContain checks if a directory may contain matches of the pattern
+func (p globPattern) mayContain(dir string) bool {
+	return matchSegments(p.segments, strings.Split(dir, "/"), true)
+}
+
+// containsAll checks if all paths in a directory match the pattern
+//
+// This is the case if the pattern ends with ** and matches the directory.
+func (p globPattern) containsAll(dir string) bool {
+	return p.segments[len(p.segments)-1].doubleStar && p.match(dir)
+}
+
+// matchSegments matches the segments of a path
+//
+// Partial matches the segments of a directory which may contain matching paths.
+func matchSegments(segments []globSegment, names []string, partial bool) bool {
+	for len(segments) > 0 {
+		if segments[0].doubleStar {
+			if partial {
+				return true
+			}
+			for skip := 0; skip <= len(names); skip++ {
+				if matchSegments(segments[1:], names[skip:], partial) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return partial
+		}
+		if !segments[0].match(names[0]) {
+			return false
+		}
+		segments, names = segments[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// globWalker walks the directories that may contain matches of patterns with the same prefix
+type globWalker struct {
+	fs       afero.Fs
+	globber  Globber
+	patterns []globPattern
+	exclude  []globPattern
+	matches  map[string]bool
+	// ancestors are the directories that are currently walked
+	ancestors []iofs.FileInfo
+}
+
+func (w *globWalker) walk() error {
+	prefix := path.Join(w.patterns[0].prefix()...)
+	root := prefix
+	if root == "" {
+		root = "."
+	} else if w.excluded(prefix) {
+		return nil
+	}
+	info, err := w.fs.Stat(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to glob %s: %v", root, err)
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	return w.walkDir(prefix, info)
+}
+
+// walkDir walks a directory whose path is relative to the root of the file system
+func (w *globWalker) walkDir(dir string, info iofs.FileInfo) error {
+	for _, ancestor := range w.ancestors {
+		if os.SameFile(ancestor, info) {
+			// symbolic link cycle
+			return nil
+		}
+	}
+	w.ancestors = append(w.ancestors, info)
+	defer func() { w.ancestors = w.ancestors[:len(w.ancestors)-1] }()
 
-	regexpPat := regexp.MustCompile(toRegexp(pattern))
+	name := dir
+	if name == "" {
+		name = "."
+	}
+	entries, err := afero.ReadDir(w.fs, filepath.FromSlash(name))
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %v", name, err)
+	}
+	for _, entry := range entries {
+		if err := w.visit(path.Join(dir, entry.Name()), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	err := afero.Walk(fs, ".", func(path string, d iofs.FileInfo, err error) error {
-		if d.IsDir() || err != nil {
+func (w *globWalker) visit(file string, entry iofs.FileInfo) error {
+	if entry.Mode()&iofs.ModeSymlink != 0 {
+		resolved, err := w.fs.Stat(filepath.FromSlash(file))
+		if err != nil {
+			// broken symbolic link
 			return nil
 		}
-		if regexpPat.MatchString(path) {
-			files = append(files, path)
+		entry = resolved
+	}
+	if w.globber.Ignore != nil && w.globber.Ignore(file, entry.IsDir()) {
+		return nil
+	}
+	if entry.IsDir() {
+		if !w.mayContain(file) || w.excluded(file) {
+			return nil
 		}
+		return w.walkDir(file, entry)
+	}
+	if !w.match(file) {
 		return nil
-	})
+	}
+	for _, excluded := range w.exclude {
+		if excluded.match(file) {
+			return nil
+		}
+	}
+	w.matches[file] = true
+	return nil
+}
+
+// match checks if a file matches one of the patterns
+func (w *globWalker) match(file string) bool {
+	for _, pattern := range w.patterns {
+		if pattern.match(file) {
+			return true
+		}
+	}
+	return false
+}
 
-	return files, err
+// mayContain checks if a directory may contain matches of one of the patterns
+func (w *globWalker) mayContain(dir string) bool {
+	for _, pattern := range w.patterns {
+		if pattern.mayContain(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded checks if all files in a directory are excluded by a negated pattern
+func (w *globWalker) excluded(dir string) bool {
+	for _, excluded := range w.exclude {
+		if excluded.containsAll(dir) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,150 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/spf13/afero"
+)
+
+func globFS(t *testing.T, files ...string) afero.Fs {
+	fs := afero.NewMemMapFs()
+	for _, file := range files {
+		if err := afero.WriteFile(fs, file, []byte{}, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	return fs
+}
+
+func TestGlob(t *testing.T) {
+	fs := globFS(t,
+		"README.md",
+		"CHANGELOG.md",
+		"a.go",
+		"b.go",
+		"c.txt",
+		"[x].md",
+		"docs/intro.md",
+		"docs/guide/setup.md",
+		"docs/guide/setup.txt",
+		"docs/vendor/lib.md",
+		"node_modules/pkg/README.md",
+		".git/info/exclude.md",
+	)
+	cases := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"*.md"}, []string{"CHANGELOG.md", "README.md", "[x].md"}},
+		{[]string{"./*.go"}, []string{"a.go", "b.go"}},
+		{[]string{"?.go"}, []string{"a.go", "b.go"}},
+		{[]string{"[a-b].go"}, []string{"a.go", "b.go"}},
+		{[]string{"[!a].go"}, []string{"b.go"}},
+		{[]string{`\[x\].md`}, []string{"[x].md"}},
+		{[]string{"*.{go,txt}"}, []string{"a.go", "b.go", "c.txt"}},
+		{[]string{"docs/*.md"}, []string{"docs/intro.md"}},
+		{[]string{"docs/**"}, []string{
+			"docs/guide/setup.md", "docs/guide/setup.txt",
+			"docs/intro.md", "docs/vendor/lib.md",
+		}},
+		{[]string{"docs/**/*.md", "!**/vendor/**"}, []string{
+			"docs/guide/setup.md", "docs/intro.md",
+		}},
+		{[]string{"**/setup.{md,txt}"}, []string{
+			"docs/guide/setup.md", "docs/guide/setup.txt",
+		}},
+		{[]string{"!**/*.{md,go}"}, []string{"c.txt", "docs/guide/setup.txt"}},
+		{[]string{"**/README.md"}, []string{"README.md"}},
+		{[]string{"node_modules/**/*.md"}, []string{"node_modules/pkg/README.md"}},
+		{[]string{"missing/*.md"}, []string{}},
+		{[]string{"README.md"}, []string{"README.md"}},
+	}
+	for _, c := range cases {
+		files, err := Glob(fs, c.patterns...)
+		if err != nil {
+			t.Fatalf("failed to glob %v: %v", c.patterns, err)
+		}
+		var expected []string
+		for _, file := range c.expected {
+			expected = append(expected, filepath.FromSlash(file))
+		}
+		if diff := cmp.Diff(expected, files, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("unexpected files for %v: %s", c.patterns, diff)
+		}
+	}
+}
+
+// countingFs counts how often each directory is read
+type countingFs struct {
+	afero.Fs
+	reads map[string]int
+}
+
+func (fs countingFs) Open(name string) (afero.File, error) {
+	if info, err := fs.Fs.Stat(name); err == nil && info.IsDir() {
+		fs.reads[filepath.ToSlash(name)]++
+	}
+	return fs.Fs.Open(name)
+}
+
+func TestGlobWalksOnce(t *testing.T) {
+	fs := countingFs{
+		Fs: globFS(t,
+			"README.md",
+			"docs/intro.rst",
+			"docs/guide/setup.md",
+			"vendor/lib/README.md",
+		),
+		reads: make(map[string]int),
+	}
+	files, err := Globber{}.Glob(fs, "**/*.{md,markdown,rst,adoc}", "!vendor/**")
+	if err != nil {
+		t.Fatalf("failed to glob: %v", err)
+	}
+	expected := []string{
+		"README.md",
+		filepath.Join("docs", "guide", "setup.md"),
+		filepath.Join("docs", "intro.rst"),
+	}
+	if diff := cmp.Diff(expected, files); diff != "" {
+		t.Errorf("unexpected files: %s", diff)
+	}
+	// the alternatives are matched in one walk and the excluded directory is pruned
+	expectedReads := map[string]int{".": 1, "docs": 1, "docs/guide": 1}
+	if diff := cmp.Diff(expectedReads, fs.reads); diff != "" {
+		t.Errorf("unexpected directory reads: %s", diff)
+	}
+}
+
+func TestGlobInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"*.{md", "*.md}", ""} {
+		if _, err := Glob(afero.NewMemMapFs(), pattern); err == nil {
+			t.Errorf("expected pattern %q to be invalid", pattern)
+		}
+	}
+}
+
+func TestGlobSymlinkCycle(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "intro.md"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(dir, "docs", "parent")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	files, err := Glob(DirFS(afero.NewOsFs(), dir), "**/*.md")
+	if err != nil {
+		t.Fatalf("failed to glob: %v", err)
+	}
+	expected := []string{filepath.Join("docs", "intro.md")}
+	if diff := cmp.Diff(expected, files); diff != "" {
+		t.Errorf("unexpected files: %s", diff)
+	}
+}
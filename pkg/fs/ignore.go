@@ -0,0 +1,205 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ignoreRule is a pattern of an ignore file
+type ignoreRule struct {
+	// base is the directory of the ignore file relative to the root
+	base    string
+	pattern globPattern
+	negated bool
+	dirOnly bool
+}
+
+// matches checks if a path relative to the root matches the rule
+func (r ignoreRule) matches(file string, dir bool) bool {
+	if r.dirOnly && !dir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(file, r.base+"/") {
+			return false
+		}
+		file = file[len(r.base)+1:]
+	}
+	return r.pattern.match(file)
+}
+
+// parseIgnoreRules parses the lines of an ignore file in a directory
+//
+// Lines that are not valid patterns are skipped like in git.
+func parseIgnoreRules(base string, content string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		if rule, ok := parseIgnoreRule(base, line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreRule parses a line of an ignore file (see gitignore(5))
+func parseIgnoreRule(base string, line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless they are escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negated = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		// patterns with a slash are relative to the directory of the ignore file
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	pattern, err := compilePattern(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// GitIgnore matches paths with the ignore files in the directories of a tree like git
+//
+// The ignore files of a directory are loaded when a path in the directory is matched.
+// Patterns are relative to the directory of their ignore file, later patterns
+// override earlier patterns and the ignore files of subdirectories override the
+// ignore files of their parents. Files in ignored directories cannot be re-included.
+type GitIgnore struct {
+	fs afero.Fs
+	// root is the directory whose ignore files are loaded (e.g. the repository)
+	root string
+	// prefix is the path of the directory of relative paths relative to the root
+	prefix string
+	files  []string
+	rules  []ignoreRule
+	loaded map[string]bool
+	err    error
+}
+
+// NewGitIgnore creates a matcher for the ignore files of a root directory
+//
+// Relative paths are relative to dir, which must be inside of root.
+// Files are the names of the ignore files in each directory (e.g. .gitignore).
+func NewGitIgnore(fs afero.Fs, root string, dir string, files ...string) (*GitIgnore, error) {
+	prefix, err := filepath.Rel(root, dir)
+	if err != nil || strings.HasPrefix(filepath.ToSlash(prefix), "../") || prefix == ".." {
+		return nil, fmt.Errorf("%s is not inside of %s", dir, root)
+	}
+	prefix = filepath.ToSlash(prefix)
+	if prefix == "." {
+		prefix = ""
+	}
+	return &GitIgnore{
+		fs:     fs,
+		root:   root,
+		prefix: prefix,
+		files:  files,
+		loaded: make(map[string]bool),
+	}, nil
+}
+
+// AddFile adds the patterns of an ignore file that applies to the whole root
+// (e.g. .git/info/exclude)
+//
+// Files must be added before paths are matched. Files that were added later
+// override files that were added before and all of them are overridden
+// by the ignore files in the directories of the root.
+// Files that do not exist are skipped.
+func (g *GitIgnore) AddFile(file string) error {
+	content, err := afero.ReadFile(g.fs, file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ignore file %s: %v", file, err)
+	}
+	g.rules = append(g.rules, parseIgnoreRules("", string(content))...)
+	return nil
+}
+
+// Err returns the first error reading an ignore file of a directory
+func (g *GitIgnore) Err() error {
+	return g.err
+}
+
+// Ignored checks if a path is ignored
+//
+// Relative paths are relative to the directory of the matcher.
+// Paths outside of the root are never ignored.
+func (g *GitIgnore) Ignored(file string, dir bool) bool {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(g.root, file)
+		if err != nil {
+			return false
+		}
+		file = rel
+	} else {
+		file = filepath.Join(filepath.FromSlash(g.prefix), file)
+	}
+	file = filepath.ToSlash(file)
+	if file == "." || file == ".." || strings.HasPrefix(file, "../") {
+		return false
+	}
+
+	parents := strings.Split(file, "/")
+	g.load("")
+	for i := 1; i < len(parents); i++ {
+		parent := path.Join(parents[:i]...)
+		if g.match(parent, true) {
+			return true
+		}
+		g.load(parent)
+	}
+	return g.match(file, dir)
+}
+
+// match returns if the last rule matching a path ignores it
+func (g *GitIgnore) match(file string, dir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.matches(file, dir) {
+			ignored = !rule.negated
+		}
+	}
+	return ignored
+}
+
+// load loads the ignore files of a directory relative to the root
+func (g *GitIgnore) load(dir string) {
+	if g.loaded[dir] {
+		return
+	}
+	g.loaded[dir] = true
+	for _, name := range g.files {
+		file := filepath.Join(g.root, filepath.FromSlash(dir), name)
+		content, err := afero.ReadFile(g.fs, file)
+		if err != nil {
+			if !os.IsNotExist(err) && g.err == nil {
+				g.err = fmt.Errorf("failed to read ignore file %s: %v", file, err)
+			}
+			continue
+		}
+		g.rules = append(g.rules, parseIgnoreRules(dir, string(content))...)
+	}
+}
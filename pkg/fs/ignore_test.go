@@ -0,0 +1,74 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestGitIgnore(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/.gitignore":        "# build output\n*.log\n!keep.log\n/build/\ntmp/\n",
+		"/repo/docs/.gitignore":   "draft.md\n/generated\n",
+		"/repo/docs/sub/.ignore":  "*.md\n",
+		"/repo/.git/info/exclude": "local.md\n",
+		"/home/excludes":          "*.bak\nlocal.md\n",
+		"/repo/vendor/.gitignore": "!*.log\n",
+	}
+	for file, content := range files {
+		if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gi, err := NewGitIgnore(fs, "/repo", "/repo/docs", ".gitignore", ".ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"/home/excludes", "/repo/.git/info/exclude"} {
+		if err := gi.AddFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		path    string
+		dir     bool
+		ignored bool
+	}{
+		{"intro.md", false, false},
+		{"draft.md", false, true},
+		{"sub/draft.md", false, true},
+		{"/repo/draft.md", false, false},
+		{"generated", true, true},
+		{"sub/generated", true, false},
+		{"sub/intro.md", false, true},
+		{"sub/intro.txt", false, false},
+		{"debug.log", false, true},
+		{"keep.log", false, false},
+		{"notes.bak", false, true},
+		{"local.md", false, true},
+		{"/repo/build", true, true},
+		{"/repo/build/out.txt", false, true},
+		{"/repo/docs/build", true, false},
+		{"tmp", false, false},
+		{"tmp", true, true},
+		{"tmp/file.txt", false, true},
+		{"/repo/vendor/debug.log", false, false},
+		{"/outside/debug.log", false, false},
+	}
+	for _, c := range cases {
+		if ignored := gi.Ignored(c.path, c.dir); ignored != c.ignored {
+			t.Errorf("expected %s (dir=%t) ignored=%t but got %t", c.path, c.dir, c.ignored, ignored)
+		}
+	}
+	if err := gi.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGitIgnoreOutsideRoot(t *testing.T) {
+	if _, err := NewGitIgnore(afero.NewMemMapFs(), "/repo", "/other"); err == nil {
+		t.Errorf("expected error for directory outside of the root")
+	}
+}
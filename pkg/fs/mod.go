@@ -22,3 +22,15 @@ func EnsureFile(fs afero.Fs, path string) error {
 	}
 	return nil
 }
+
+// EnsureDir ensures the presence of a directory for a path
+func EnsureDir(fs afero.Fs, path string) error {
+	stat, err := fs.Stat(path)
+	if err != nil {
+		return fmt.Errorf("directory %s does not exist", path)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package embedme
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+	"golang.org/x/exp/maps"
+)
+
+// GoSymbolExtractor extracts symbols from Go source files
+//
+// Supported selectors are:
+//   - "Example_basic" or "example:Example_basic" selects the body of an example
+//   - "output:Example_basic" selects the expected output of an example
+//   - "func:Greet" or "func:Greeter.Greet" selects a function or method
+//   - "type:Greeter" selects a type declaration
+//   - "Greeter" or "Greeter.Greet" selects a type or else a function or method
+type GoSymbolExtractor struct{}
+
+// Extract ...
+func (GoSymbolExtractor) Extract(path string, content []byte, kind string, name string) ([]string, error) {
+	if kind == "" && strings.HasPrefix(name, "Example") {
+		kind = "example"
+	}
+	switch kind {
+	case "example", "output":
+		fset, example, err := goExample(path, content, name)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "output" {
+			return internal.GoExampleOutput(example.Output), nil
+		}
+		body, ok := example.Code.(*ast.BlockStmt)
+		if !ok {
+			return nil, fmt.Errorf("example %s has no body", name)
+		}
+		return internal.GoExampleBody(fset, body, example.Comments)
+	case "", "func", "type":
+		return goDecl(path, content, kind, name)
+	}
+	return nil, fmt.Errorf("unsupported Go selector %q", kind+":"+name)
+}
+
+// goDecl selects the source lines of a function or type declaration including its doc comment
+//
+// Types are preferred over functions if the kind is empty.
+func goDecl(path string, content []byte, kind string, name string) ([]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if kind == "" {
+		if lines, ok := goDeclLines(fset, file, content, "type", name); ok {
+			return lines, nil
+		}
+		if lines, ok := goDeclLines(fset, file, content, "func", name); ok {
+			return lines, nil
+		}
+		return nil, symbolNotFound(path, "type or func", name)
+	}
+	if lines, ok := goDeclLines(fset, file, content, kind, name); ok {
+		return lines, nil
+	}
+	return nil, symbolNotFound(path, kind, name)
+}
+
+// goDeclLines selects the source lines of a declaration of a parsed file
+func goDeclLines(fset *token.FileSet, file *ast.File, content []byte, kind string, name string) ([]string, bool) {
+	for _, decl := range file.Decls {
+		var doc *ast.CommentGroup
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			funcName := decl.Name.Name
+			if recv := receiverType(decl); recv != "" {
+				funcName = recv + "." + funcName
+			}
+			if kind != "func" || funcName != name {
+				continue
+			}
+			doc = decl.Doc
+		case *ast.GenDecl:
+			if kind != "type" || decl.Tok != token.TYPE || !declaresType(decl, name) {
+				continue
+			}
+			doc = decl.Doc
+		}
+		start := decl.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		source := content[fset.Position(start).Offset:fset.Position(decl.End()).Offset]
+		return internal.Lines(string(source)), true
+	}
+	return nil, false
+}
+
+func declaresType(decl *ast.GenDecl, name string) bool {
+	for _, spec := range decl.Specs {
+		if spec.(*ast.TypeSpec).Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// goExample finds an example function in a Go test file
+func goExample(path string, content []byte, name string) (*token.FileSet, *doc.Example, error) {
+	if !strings.HasSuffix(path, "_test.go") {
+		return nil, nil, fmt.Errorf("examples must be in a _test.go file, but got %s", path)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	for _, example := range doc.Examples(file) {
+		funcName := "Example" + example.Name
+		if example.Suffix != "" {
+			funcName += "_" + example.Suffix
+		}
+		if funcName == name {
+			return fset, example, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("example %s not found in %s", name, path)
+}
+
+// GoOutliner outlines Go source files
+//
+// The outline contains the package clause, all type declarations followed by their methods,
+// and all remaining functions. Function bodies are replaced by "{ ... }".
+type GoOutliner struct{}
+
+// Outline ...
+func (GoOutliner) Outline(path string, content []byte) ([]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	methods := make(map[string][]*ast.FuncDecl)
+	var funcs []*ast.FuncDecl
+	var typeDecls []*ast.GenDecl
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			typeDecls = append(typeDecls, decl)
+		case *ast.FuncDecl:
+			if recv := receiverType(decl); recv != "" {
+				methods[recv] = append(methods[recv], decl)
+			} else {
+				funcs = append(funcs, decl)
+			}
+		}
+	}
+
+	outline := goOutline{fset: fset}
+	outline.add("package " + file.Name.Name)
+	for _, decl := range typeDecls {
+		outline.group()
+		outline.node(decl)
+		for _, spec := range decl.Specs {
+			name := spec.(*ast.TypeSpec).Name.Name
+			outline.funcs(methods[name])
+			delete(methods, name)
+		}
+	}
+	// methods of types that are declared in other files
+	others := maps.Keys(methods)
+	sort.Strings(others)
+	for _, name := range others {
+		outline.group()
+		outline.funcs(methods[name])
+	}
+	if len(funcs) > 0 {
+		outline.group()
+		outline.funcs(funcs)
+	}
+	return outline.lines, outline.err
+}
+
+type goOutline struct {
+	fset  *token.FileSet
+	lines []string
+	err   error
+}
+
+func (o *goOutline) add(lines ...string) {
+	o.lines = append(o.lines, lines...)
+}
+
+func (o *goOutline) group() {
+	o.add("")
+}
+
+func (o *goOutline) node(node interface{}) {
+	lines, err := internal.FormatGoNode(o.fset, node)
+	if err != nil {
+		o.err = err
+		return
+	}
+	o.add(lines...)
+}
+
+func (o *goOutline) funcs(funcs []*ast.FuncDecl) {
+	for _, fun := range funcs {
+		signature := *fun
+		signature.Body = nil
+		lines, err := internal.FormatGoNode(o.fset, &signature)
+		if err != nil {
+			o.err = err
+			return
+		}
+		lines[len(lines)-1] += " { ... }"
+		o.add(lines...)
+	}
+}
+
+// receiverType returns the name of the receiver type of a method
+func receiverType(fun *ast.FuncDecl) string {
+	if fun.Recv == nil || len(fun.Recv.List) == 0 {
+		return ""
+	}
+	typ := fun.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -1,201 +1,344 @@
 package embedme
 
+import (
+	// embed the language table
+	_ "embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
 // LanguageID describes a programming language
 type LanguageID string
 
-var (
-	// PlainText file extensions
-	PlainText = []LanguageID{"txt", "embedme"}
-	// None contains no text file extensions
-	None = []LanguageID{""}
-	// Typescript file extensions
-	Typescript = []LanguageID{"ts"}
-	// Javascript file extensions
-	Javascript = []LanguageID{"js"}
-	// Reason file extensions
-	Reason = []LanguageID{"re"}
-	// Scss file extensions
-	Scss = []LanguageID{"scss"}
-	// Rust file extensions
-	Rust = []LanguageID{"rust"}
-	// Java file extensions
-	Java = []LanguageID{"java"}
-	// Cpp file extensions
-	Cpp = []LanguageID{"cpp"}
-	// C file extensions
-	C = []LanguageID{"c"}
-	// HTML file extensions
-	HTML = []LanguageID{"html"}
-	// XML file extensions
-	XML = []LanguageID{"xml"}
-	// Markdown file extensions
-	Markdown = []LanguageID{"md"}
-	// TOML file extensions
-	TOML = []LanguageID{"toml"}
-	// YAML file extensions
-	YAML = []LanguageID{"yaml"}
-	// JSON file extensions
-	JSON = []LanguageID{"json"}
-	// JSON5 file extensions
-	JSON5 = []LanguageID{"json5"}
-	// Python file extensions
-	Python = []LanguageID{"py", "python"}
-	// Bash file extensions
-	Bash = []LanguageID{"bash"}
-	// Shell file extensions
-	Shell = []LanguageID{"sh", "shell"}
-	// Golang file extensions
-	Golang = []LanguageID{"go", "golang"}
-	// ObjectiveC file extensions
-	ObjectiveC = []LanguageID{"objectivec", "objc"}
-	// PHP file extensions
-	PHP = []LanguageID{"php"}
-	// CSharp file extensions
-	CSharp = []LanguageID{"cs"}
-	// Swift file extensions
-	Swift = []LanguageID{"swift"}
-	// Ruby file extensions
-	Ruby = []LanguageID{"rb"}
-	// Kotlin file extensions
-	Kotlin = []LanguageID{"kotlin"}
-	// Scala file extensions
-	Scala = []LanguageID{"scala"}
-	// Crystal file extensions
-	Crystal = []LanguageID{"cr"}
-	// PlantUML file extensions
-	PlantUML = []LanguageID{"puml"}
-	// Mermaid file extensions
-	Mermaid = []LanguageID{"mermaid"}
-	// Cmake file extensions
-	Cmake = []LanguageID{"cmake"}
-	// Protobuf file extensions
-	Protobuf = []LanguageID{"proto"}
-	// SQL file extensions
-	SQL = []LanguageID{"sql"}
-	// Haskell file extensions
-	Haskell = []LanguageID{"hs"}
-	// Arduino file extensions
-	Arduino = []LanguageID{"ino"}
-	// Jsx file extensions
-	Jsx = []LanguageID{"jsx"}
-	// Tsx file extensions
-	Tsx = []LanguageID{"tsx"}
-)
+// Language is a programming language and its comments
+type Language struct {
+	// Name of the language
+	Name string `yaml:"name"`
+	// Aliases are the code block tags and file extensions of the language
+	Aliases []LanguageID `yaml:"aliases"`
+	// Filenames are the names of files of the language without a distinctive extension
+	Filenames []string `yaml:"filenames,omitempty"`
+	// Interpreters are the interpreters of scripts of the language (e.g. python3)
+	Interpreters []string `yaml:"interpreters,omitempty"`
+	// LineComment starts a comment that ends with the line (e.g. //)
+	LineComment string `yaml:"line_comment,omitempty"`
+	// BlockComment starts and ends a block comment (e.g. /* and */)
+	BlockComment []string `yaml:"block_comment,omitempty"`
+	// Compatible are the names of related languages, whose code can be embedded
+	// into code blocks of the other language (e.g. Shell for Bash)
+	Compatible []string `yaml:"compatible,omitempty"`
+}
 
-// CommentType describes the type of comment used by a programming language
-type CommentType uint32
-
-const (
-	// CommentNone refers to no comment
-	CommentNone CommentType = iota
-	// CommentDoubleSlash refers to "//" comment
-	CommentDoubleSlash
-	// CommentXML refers to "<!-- ... -->" comment
-	CommentXML
-	// CommentHash refers to "#" comment
-	CommentHash
-	// CommentSingleQuote refers to "'" comment
-	CommentSingleQuote
-	// CommentDoublePercent refers to "%%" comment
-	CommentDoublePercent
-	// CommentDoubleHyphens refers to "--" comment
-	CommentDoubleHyphens
-)
+// ID returns the first alias of the language
+func (l *Language) ID() LanguageID {
+	if len(l.Aliases) == 0 {
+		return ""
+	}
+	return l.Aliases[0]
+}
+
+// compatibleWith checks if the languages are the same or one is compatible with the other
+func (l *Language) compatibleWith(other *Language) bool {
+	if l.Name == other.Name {
+		return true
+	}
+	for _, name := range l.Compatible {
+		if name == other.Name {
+			return true
+		}
+	}
+	for _, name := range other.Compatible {
+		if name == l.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasComments checks if the language has line or block comments
+func (l *Language) HasComments() bool {
+	return l.LineComment != "" || len(l.BlockComment) == 2
+}
+
+// CommentDelimiters returns the start and end of an embed comment
+//
+// Line comments are preferred over block comments.
+// The end is empty for line comments.
+func (l *Language) CommentDelimiters() (string, string) {
+	if l.LineComment != "" {
+		return l.LineComment, ""
+	}
+	if len(l.BlockComment) == 2 {
+		return l.BlockComment[0], l.BlockComment[1]
+	}
+	return "", ""
+}
+
+// Comment comments text (e.g. // text or <!-- text -->)
+func (l *Language) Comment(text string) string {
+	start, end := l.CommentDelimiters()
+	if end == "" {
+		return start + " " + text
+	}
+	return start + " " + text + " " + end
+}
+
+// commentString describes the comments of the language (e.g. // or /* ... */)
+func (l *Language) commentString() string {
+	var comments []string
+	if l.LineComment != "" {
+		comments = append(comments, l.LineComment)
+	}
+	if len(l.BlockComment) == 2 {
+		comments = append(comments, l.BlockComment[0]+" ... "+l.BlockComment[1])
+	}
+	return strings.Join(comments, " or ")
+}
 
 var (
-	// LanguageComments ...
-	LanguageComments = map[CommentType][]LanguageID{
-		CommentNone: concat(JSON),
-		CommentDoubleSlash: concat(
-			None,      // we define no-language to use double slash
-			PlainText, // we define plaintext to use double slash
-			C,
-			Typescript,
-			Reason,
-			Javascript,
-			Rust,
-			Cpp,
-			Java,
-			Golang,
-			ObjectiveC,
-			Scss,
-			PHP,
-			CSharp,
-			Swift,
-			Kotlin,
-			Scala,
-			JSON5,
-			Protobuf,
-			Arduino,
-			Jsx,
-			Tsx,
-		),
-		CommentXML: concat(
-			HTML,
-			Markdown,
-			XML,
-		),
-		CommentHash: concat(
-			Python,
-			Bash,
-			Shell,
-			YAML,
-			TOML,
-			Ruby,
-			Crystal,
-			Cmake,
-		),
-		CommentSingleQuote: concat(
-			PlantUML,
-		),
-		CommentDoublePercent: concat(
-			Mermaid,
-		),
-		CommentDoubleHyphens: concat(
-			SQL,
-			Haskell,
-		),
-	}
-	// SupportedLanguages ...
-	SupportedLanguages = buildSupportedLanguages(LanguageComments)
-	// CommentForLanguage ...
-	CommentForLanguage = buildCommentForLanguage(LanguageComments)
+	// firstCommentRegexesForComments caches the first comment expressions for the comments of languages
+	firstCommentRegexesForComments sync.Map
 )
 
-func buildSupportedLanguages(mapping map[CommentType][]LanguageID) []string {
-	var supported []string
-	for _, languages := range mapping {
-		for _, lang := range languages {
-			supported = append(supported, string(lang))
+// firstCommentRegexes returns expressions that match the text of a
+// line comment or block comment at the start of a source
+func (l *Language) firstCommentRegexes() []*regexp.Regexp {
+	key := [3]string{l.LineComment}
+	if len(l.BlockComment) == 2 {
+		key[1], key[2] = l.BlockComment[0], l.BlockComment[1]
+	}
+	if regexes, ok := firstCommentRegexesForComments.Load(key); ok {
+		return regexes.([]*regexp.Regexp)
+	}
+	var regexes []*regexp.Regexp
+	if l.LineComment != "" {
+		// independent of newline type
+		regexes = append(regexes, regexp.MustCompile(
+			`^\s*`+regexp.QuoteMeta(l.LineComment)+`([\s\S]*?)\r?\n`,
+		))
+	}
+	if len(l.BlockComment) == 2 {
+		regexes = append(regexes, regexp.MustCompile(
+			`^\s*`+regexp.QuoteMeta(l.BlockComment[0])+
+				`\s*?(\S*?)\s*?`+regexp.QuoteMeta(l.BlockComment[1]),
+		))
+	}
+	firstCommentRegexesForComments.Store(key, regexes)
+	return regexes
+}
+
+// validate checks that a language has a name, aliases and well-formed comments
+func (l *Language) validate() error {
+	if l.Name == "" {
+		return fmt.Errorf("language %v has no name", l.Aliases)
+	}
+	if len(l.Aliases) == 0 {
+		return fmt.Errorf("language %s has no aliases", l.Name)
+	}
+	if l.BlockComment != nil && len(l.BlockComment) != 2 {
+		return fmt.Errorf(
+			"block comment of language %s must be a start and an end (got %d values)",
+			l.Name, len(l.BlockComment),
+		)
+	}
+	return nil
+}
+
+// LanguageRegistry maps the aliases and file names of languages to languages
+type LanguageRegistry struct {
+	aliases      map[LanguageID]*Language
+	filenames    map[string]*Language
+	interpreters map[string]*Language
+}
+
+// NewLanguageRegistry creates a registry of languages
+func NewLanguageRegistry(languages ...Language) *LanguageRegistry {
+	registry := &LanguageRegistry{
+		aliases:      make(map[LanguageID]*Language),
+		filenames:    make(map[string]*Language),
+		interpreters: make(map[string]*Language),
+	}
+	registry.Register(languages...)
+	return registry
+}
+
+// Register adds languages to the registry
+//
+// Languages override the languages that were registered before
+// for the same aliases or file names.
+func (r *LanguageRegistry) Register(languages ...Language) {
+	for i := range languages {
+		lang := languages[i]
+		for _, alias := range lang.Aliases {
+			r.aliases[LanguageID(strings.ToLower(string(alias)))] = &lang
 		}
+		for _, filename := range lang.Filenames {
+			r.filenames[filename] = &lang
+		}
+		for _, interpreter := range lang.Interpreters {
+			r.interpreters[interpreter] = &lang
+		}
+	}
+}
+
+// With returns a copy of the registry with additional languages
+//
+// The languages override the languages of the registry (see Register).
+func (r *LanguageRegistry) With(languages ...Language) *LanguageRegistry {
+	registry := NewLanguageRegistry()
+	for alias, lang := range r.aliases {
+		registry.aliases[alias] = lang
+	}
+	for filename, lang := range r.filenames {
+		registry.filenames[filename] = lang
+	}
+	for interpreter, lang := range r.interpreters {
+		registry.interpreters[interpreter] = lang
+	}
+	registry.Register(languages...)
+	return registry
+}
+
+// Lookup returns the language with a (case insensitive) alias
+func (r *LanguageRegistry) Lookup(id LanguageID) (*Language, bool) {
+	lang, ok := r.aliases[LanguageID(strings.ToLower(string(id)))]
+	return lang, ok
+}
+
+// LookupFilename returns the language of files with a name
+func (r *LanguageRegistry) LookupFilename(name string) (*Language, bool) {
+	lang, ok := r.filenames[name]
+	return lang, ok
+}
+
+// LookupInterpreter returns the language of scripts run by an interpreter
+func (r *LanguageRegistry) LookupInterpreter(interpreter string) (*Language, bool) {
+	lang, ok := r.interpreters[interpreter]
+	return lang, ok
+}
+
+// Aliases returns the aliases of the languages with names
+func (r *LanguageRegistry) Aliases(names ...string) []LanguageID {
+	var aliases []LanguageID
+	for alias, lang := range r.aliases {
+		for _, name := range names {
+			if lang.Name == name {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i] < aliases[j] })
+	return aliases
+}
+
+// Supported returns all aliases in sorted order
+func (r *LanguageRegistry) Supported() []string {
+	var supported []string
+	for alias := range r.aliases {
+		supported = append(supported, string(alias))
 	}
+	sort.Strings(supported)
 	return supported
 }
 
-func buildCommentForLanguage(mapping map[CommentType][]LanguageID) map[LanguageID]CommentType {
-	result := make(map[LanguageID]CommentType)
-	for comment, languages := range mapping {
-		for _, lang := range languages {
-			result[lang] = comment
+// ParseLanguages parses a YAML table of languages
+func ParseLanguages(data []byte) ([]Language, error) {
+	var languages []Language
+	if err := yaml.Unmarshal(data, &languages); err != nil {
+		return nil, err
+	}
+	seen := make(map[LanguageID]string)
+	for i := range languages {
+		lang := &languages[i]
+		if err := lang.validate(); err != nil {
+			return nil, err
+		}
+		for _, alias := range lang.Aliases {
+			if other, ok := seen[alias]; ok {
+				return nil, fmt.Errorf("alias %q of language %s is also an alias of %s", alias, lang.Name, other)
+			}
+			seen[alias] = lang.Name
 		}
 	}
-	return result
+	return languages, nil
 }
 
-func concat[T any](slices ...[]T) []T {
-	var totalLen int
+var (
+	//go:embed languages.yaml
+	languagesTable []byte
+	// Languages are the languages known to embedme
+	Languages = NewLanguageRegistry(mustParseLanguages(languagesTable)...)
+)
 
-	for _, s := range slices {
-		totalLen += len(s)
+func mustParseLanguages(data []byte) []Language {
+	languages, err := ParseLanguages(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid language table: %v", err))
 	}
+	return languages
+}
 
-	result := make([]T, totalLen)
+// registryOrDefault returns a registry or the known languages if it is nil
+func registryOrDefault(registry *LanguageRegistry) *LanguageRegistry {
+	if registry == nil {
+		return Languages
+	}
+	return registry
+}
 
-	var i int
+// registry returns the known languages overridden by the languages of the options
+func (o *Options) registry() *LanguageRegistry {
+	if len(o.Languages) == 0 {
+		return Languages
+	}
+	return Languages.With(o.Languages...)
+}
 
-	for _, s := range slices {
-		i += copy(result[i:], s)
+// language returns a language by alias
+//
+// The languages of the options override the known languages.
+func (o *Options) language(id LanguageID) (*Language, error) {
+	for i := len(o.Languages) - 1; i >= 0; i-- {
+		for _, alias := range o.Languages[i].Aliases {
+			if strings.EqualFold(string(alias), string(id)) {
+				return &o.Languages[i], nil
+			}
+		}
+	}
+	if lang, ok := Languages.Lookup(id); ok {
+		return lang, nil
 	}
+	return nil, fmt.Errorf(
+		"unsupported file extension %q (must be one of %+v)",
+		id, Languages.Supported(),
+	)
+}
+
+var (
+	// shebangRegex matches the interpreter of a shebang (e.g. #!/usr/bin/env python3)
+	shebangRegex = regexp.MustCompile(`^#!\s*(\S*/)?(env\s+(-\S+\s+)*)?([^\s/]+)`)
+)
 
-	return result
+// shebangLanguage returns the language of the interpreter in a shebang line
+//
+// The languages of the options override the known languages.
+func (o *Options) shebangLanguage(line string) (*Language, bool) {
+	match := shebangRegex.FindStringSubmatch(line)
+	if match == nil {
+		return nil, false
+	}
+	interpreter := match[4]
+	for i := len(o.Languages) - 1; i >= 0; i-- {
+		for _, candidate := range o.Languages[i].Interpreters {
+			if candidate == interpreter {
+				return &o.Languages[i], true
+			}
+		}
+	}
+	return Languages.LookupInterpreter(interpreter)
 }
@@ -0,0 +1,204 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestLanguages(t *testing.T) {
+	for id, expected := range map[LanguageID]string{
+		"css":        "/* ... */",
+		"lua":        "-- or --[[ ... ]]",
+		"R":          "#",
+		"dockerfile": "#",
+		"makefile":   "#",
+		"terraform":  "# or /* ... */",
+		"nix":        "# or /* ... */",
+		"zig":        "//",
+		"elixir":     "#",
+		"ocaml":      "(* ... *)",
+		"powershell": "# or <# ... #>",
+		"graphql":    "#",
+		"vue":        "<!-- ... -->",
+		"json":       "",
+	} {
+		lang, ok := Languages.Lookup(id)
+		if !ok {
+			t.Errorf("%s: unsupported language", id)
+			continue
+		}
+		if comment := lang.commentString(); comment != expected {
+			t.Errorf("%s: expected comment %q but got %q", id, expected, comment)
+		}
+	}
+
+	if lang := languageForPath("build/Containerfile"); lang != "dockerfile" {
+		t.Errorf("expected Containerfile to be a dockerfile but got %q", lang)
+	}
+
+	for _, table := range []string{
+		"- name: A\n  aliases: [a]\n- name: B\n  aliases: [b, a]\n",
+		"- name: A\n  aliases: [a]\n  block_comment: [\"/*\"]\n",
+		"- aliases: [a]\n",
+		"- name: A\n",
+		"name: A",
+	} {
+		if _, err := ParseLanguages([]byte(table)); err == nil {
+			t.Errorf("expected invalid language table to fail:\n%s", table)
+		}
+	}
+}
+
+func TestFirstCommentBlock(t *testing.T) {
+	for _, c := range []struct {
+		language LanguageID
+		source   string
+		expected string
+		ok       bool
+	}{
+		{"css", "\n/* styles.css */\nbody {}\n", "styles.css", true},
+		{"ocaml", "(* main.ml#L1-2 *)\nlet x = 1\n", "main.ml#L1-2", true},
+		{"html", "  <!-- index.html -->\n<p></p>\n", "index.html", true},
+		{"haskell", "-- Main.hs\n{- ignored -}\n", " Main.hs", true},
+		{"elm", "{- Main.elm -}\nmain = 1\n", "Main.elm", true},
+		{"css", "body {}\n/* styles.css */\n", "", false},
+		{"json", "{}\n", "", true},
+	} {
+		lang, ok := Languages.Lookup(c.language)
+		if !ok {
+			t.Fatalf("%s: unsupported language", c.language)
+		}
+		comment, ok := FirstComment(c.source, lang)
+		if comment != c.expected || ok != c.ok {
+			t.Errorf("%s: expected first comment (%q, %t) but got (%q, %t)",
+				c.language, c.expected, c.ok, comment, ok)
+		}
+	}
+}
+
+func TestEmbedLanguages(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/index.html", []byte("<p>embedme</p>\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/styles.css", []byte("p {\n  color: red;\n}\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/init.fnl", []byte("(print \"embedme\")\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	options.Languages = []Language{
+		{Name: "Fennel", Aliases: []LanguageID{"fennel", "fnl"}, LineComment: ";;"},
+	}
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	source := strings.ReplaceAll(strings.TrimSpace(`
+FENCEhtml
+<!-- index.html -->
+FENCE
+
+FENCEcss
+/* styles.css */
+FENCE
+
+FENCEfennel
+;; init.fnl
+FENCE
+	`), "FENCE", "```") + "\n"
+
+	expected := strings.ReplaceAll(strings.TrimSpace(`
+FENCEhtml
+<!-- index.html -->
+
+<p>embedme</p>
+FENCE
+
+FENCEcss
+/* styles.css */
+
+p {
+  color: red;
+}
+FENCE
+
+FENCEfennel
+;; init.fnl
+
+(print "embedme")
+FENCE
+	`), "FENCE", "```") + "\n"
+
+	embedded, err := embedder.Embed([]byte(source), filepath.Join(workingDir, "README.md"), "README.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	// embedding again must not change the document
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "README.md"), "README.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
+
+func TestInferOptionsLanguages(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+	afero.WriteFile(fs, "/work/dir/Justfile", []byte("build:\n\tgo build\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	options.Languages = []Language{
+		{Name: "Just", Aliases: []LanguageID{"just"}, Filenames: []string{"Justfile"}, LineComment: "#"},
+	}
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	source := "<!-- embedme Justfile -->\n```\n```\n"
+	expected := "<!-- embedme Justfile -->\n```just\nbuild:\n\tgo build\n```\n"
+	embedded, err := embedder.Embed([]byte(source), filepath.Join(workingDir, "README.md"), "README.md")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+}
+
+func TestCompatibleLanguages(t *testing.T) {
+	for _, c := range []struct {
+		block    LanguageID
+		embedded LanguageID
+		expected bool
+	}{
+		{"bash", "sh", true},
+		{"sh", "bash", true},
+		{"ts", "tsx", true},
+		{"js", "jsx", true},
+		{"cpp", "h", true},
+		{"go", "go", true},
+		{"ts", "py", false},
+		{"c", "go", false},
+	} {
+		block, _ := Languages.Lookup(c.block)
+		embedded, _ := Languages.Lookup(c.embedded)
+		if compatible := block.compatibleWith(embedded); compatible != c.expected {
+			t.Errorf("expected %s code in %s blocks to be compatible=%v", c.embedded, c.block, c.expected)
+		}
+	}
+}
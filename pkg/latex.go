@@ -0,0 +1,163 @@
+package embedme
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches the start of a listing environment
+	latexBeginRegex = regexp.MustCompile(`^\s*\\begin\{(lstlisting|minted)\}(\[([^\]]*)\])?(\{([^}]*)\})?`)
+	// Matches the default options of listings
+	latexLstsetRegex = regexp.MustCompile(`^\s*\\lstset\{(.*)\}`)
+	// Matches an embed comment
+	latexEmbedCommentRegex = regexp.MustCompile(`^\s*%\s*embedme\s+(.+?)\s*$`)
+	// Matches an ignore next comment
+	latexIgnoreRegex = regexp.MustCompile(`^\s*%\s*embedme[ -]ignore-(next|start|end|file)\s*$`)
+	// Matches the dialect of a listings language (e.g. [Sharp]C)
+	latexDialectRegex = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+)
+
+var (
+	// latexDialects maps listings languages with a dialect to language IDs
+	latexDialects = map[string]LanguageID{
+		"[sharp]c":     "cs",
+		"[objective]c": "objc",
+	}
+)
+
+// ExtractLaTeXBlocks extracts the lstlisting and minted environments of a LaTeX document
+//
+// The language of a lstlisting is given by its language option or by \lstset.
+// The language of minted is its mandatory argument.
+//
+// An embed comment (% embedme path) or ignore next comment (% embedme-ignore-next)
+// applies to the next listing.
+func ExtractLaTeXBlocks(source string) []CodeBlock {
+	lines := sourceLines(source)
+	var blocks []CodeBlock
+	var directive Directive
+	var language LanguageID
+	for i := 0; i < len(lines); i++ {
+		text := lines[i].text
+		switch {
+		case lines[i].blank():
+		case directive.update(LaTeXFormat{}, text):
+		case latexLstsetRegex.MatchString(text):
+			options := parseLaTeXOptions(latexLstsetRegex.FindStringSubmatch(text)[1])
+			if lang, ok := options["language"]; ok {
+				language = latexLanguage(lang)
+			}
+		case latexBeginRegex.MatchString(text):
+			match := latexBeginRegex.FindStringSubmatch(text)
+			end := i + 1
+			closing := `\end{` + match[1] + `}`
+			for end < len(lines) && strings.TrimSpace(lines[end].text) != closing {
+				end++
+			}
+			if end == len(lines) {
+				return blocks
+			}
+			block := enclosedBlock(source, lines, i, end)
+			block.Info = match[3]
+			block.Attributes = parseLaTeXOptions(match[3])
+			block.Language = language
+			if lang, ok := block.Attributes["language"]; ok && match[1] == "lstlisting" {
+				block.Language = latexLanguage(lang)
+			} else if match[1] == "minted" {
+				block.Language = sourceLanguage(match[5])
+			}
+			block.EmbedComment = directive.Command
+			block.Ignore = directive.IgnoreNext
+			blocks = append(blocks, block)
+			directive = Directive{}
+			i = end
+		case strings.HasPrefix(strings.TrimSpace(text), "%"):
+			// comment
+		default:
+			directive = Directive{}
+		}
+	}
+	return blocks
+}
+
+// latexLanguage maps a listings language (e.g. {[Sharp]C}) to a language ID
+func latexLanguage(language string) LanguageID {
+	language = strings.ToLower(strings.Trim(language, "{} "))
+	if id, ok := latexDialects[language]; ok {
+		return id
+	}
+	return sourceLanguage(latexDialectRegex.ReplaceAllString(language, ""))
+}
+
+// parseLaTeXOptions parses key=value options separated by commas
+func parseLaTeXOptions(options string) map[string]string {
+	var attributes map[string]string
+	depth := 0
+	start := 0
+	for i := 0; i <= len(options); i++ {
+		if i < len(options) {
+			switch options[i] {
+			case '{':
+				depth++
+				continue
+			case '}':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		option := strings.TrimSpace(options[start:i])
+		start = i + 1
+		if parts := strings.SplitN(option, "=", 2); len(parts) == 2 {
+			attributes = setAttribute(attributes, strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		} else if option != "" {
+			attributes = setAttribute(attributes, option, "")
+		}
+	}
+	return attributes
+}
+
+// LaTeXFormat is the format of LaTeX documents
+type LaTeXFormat struct{}
+
+// Name ...
+func (LaTeXFormat) Name() string {
+	return "latex"
+}
+
+// ParseDirective parses % embedme path and % embedme-ignore-... comments
+func (LaTeXFormat) ParseDirective(line string) (Directive, bool) {
+	return parseDirective(line, latexIgnoreRegex, latexEmbedCommentRegex)
+}
+
+// CodeBlocks ...
+func (LaTeXFormat) CodeBlocks(source string) []CodeBlock {
+	return ExtractLaTeXBlocks(source)
+}
+
+// Replace ...
+func (LaTeXFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	return block.Start, block.End, code
+}
+
+// Escape refuses to embed lines that would end the verbatim environment
+//
+// Verbatim environments have no escape mechanism.
+func (LaTeXFormat) Escape(block *CodeBlock, lines []string) ([]string, error) {
+	for _, line := range lines {
+		if strings.Contains(line, block.ClosingFence) {
+			return nil, fmt.Errorf(
+				"refusing to embed %q as it contains %s",
+				line, block.ClosingFence,
+			)
+		}
+	}
+	return lines, nil
+}
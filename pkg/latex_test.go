@@ -0,0 +1,103 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestExtractLaTeXBlocks(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		source      string
+		expected    []documentBlock
+	}{
+		{
+			description: "lstlisting",
+			source:      "\\begin{lstlisting}[language=Go, caption={A, B}]\nfunc main() {}\n\\end{lstlisting}\n",
+			expected:    []documentBlock{{"go", "", false, "func main() {}\n"}},
+		},
+		{
+			description: "lstlisting with default language and dialect",
+			source: "\\lstset{language={[Sharp]C}}\n% embedme Program.cs\n\n% another comment\n" +
+				"\\begin{lstlisting}\nclass A {}\n\\end{lstlisting}\n",
+			expected: []documentBlock{{"cs", "Program.cs", false, "class A {}\n"}},
+		},
+		{
+			description: "minted",
+			source:      "% embedme-ignore-next\n  \\begin{minted}[linenos]{python}\n  print(1)\n  \\end{minted}\n",
+			expected:    []documentBlock{{"python", "", true, "print(1)\n"}},
+		},
+		{
+			description: "embed comment only applies to the next listing",
+			source:      "% embedme main.go\nText\n\\begin{minted}{go}\n\\end{minted}\n\\begin{minted}{go}\n",
+			expected:    []documentBlock{{"go", "", false, ""}},
+		},
+	} {
+		var blocks []documentBlock
+		for _, block := range ExtractLaTeXBlocks(c.source) {
+			blocks = append(blocks, documentBlock{
+				Language:     block.Language,
+				EmbedComment: block.EmbedComment,
+				Ignore:       block.Ignore,
+				Content:      block.Content(),
+			})
+		}
+		if diff := cmp.Diff(blocks, c.expected); diff != "" {
+			t.Log(c.source)
+			t.Errorf("%s: unexpected code blocks: %s", c.description, diff)
+		}
+	}
+}
+
+func TestEmbedLaTeX(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/main.go", []byte("package main\n\nfunc main() {}\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/listing.tex", []byte("\\begin{lstlisting}\n\\end{lstlisting}\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+\section{Usage}
+
+% embedme main.go
+\begin{minted}{go}
+\end{minted}
+	`) + "\n"
+
+	expected := strings.TrimSpace(`
+\section{Usage}
+
+% embedme main.go
+\begin{minted}{go}
+package main
+
+func main() {}
+\end{minted}
+	`) + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "paper.tex"), "paper.tex")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	// embedding code that ends the environment is refused
+	readme = "% embedme listing.tex\n\\begin{lstlisting}[language=TeX]\n\\end{lstlisting}\n"
+	if _, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "paper.tex"), "paper.tex"); err == nil {
+		t.Fatalf("expected embedding a listing end to fail")
+	}
+}
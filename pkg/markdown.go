@@ -0,0 +1,475 @@
+package embedme
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches an embed comment on its own line
+	embedCommentRegex = regexp.MustCompile(`^\s*<!--\s*embedme[ ]+([^\r\n]+?)\s*-->\s*$`)
+	// Matches an ignore comment (e.g. embedme-ignore-next) on its own line
+	ignoreRegex = regexp.MustCompile(`^\s*<!--\s*embedme[ -]ignore-(next|start|end|file)\s*-->\s*$`)
+	// Matches the start of an opening code fence
+	openingFenceRegex = regexp.MustCompile("^(`{3,}|~{3,})(.*)$")
+	// Matches a thematic break, which must not be confused with a list item
+	thematicBreakRegex = regexp.MustCompile(`^ {0,3}([-*_])( *[-*_]){2,}\s*$`)
+	// Matches the marker of a list item
+	listMarkerRegex = regexp.MustCompile(`^([-+*]|\d{1,9}[.)])([ \t]+|$)`)
+)
+
+// ExtractCodeBlocks extracts all fenced code blocks of a markdown document
+//
+// The document is scanned line by line following the CommonMark rules for fenced
+// code blocks: fences use at least three backticks or tildes and are closed by a
+// fence of the same character that is at least as long as the opening fence.
+// Fences can be nested in block quotes and list items. Fences in HTML comments
+// and indented code blocks are ignored, as are fences that are never closed.
+//
+// An embed comment (<!-- embedme ... -->) or ignore next comment applies to the
+// code block that directly follows it, only separated by blank lines.
+// Without an embed comment, the command can also be given by the attributes
+// of the info string, e.g. ```go file=main.go lines=3-12 or ```go {embed="main.go#L3-12"}.
+func ExtractCodeBlocks(source string) []CodeBlock {
+	return scanMarkdown(source, false)
+}
+
+// scanMarkdown extracts the code blocks of a markdown document
+//
+// If missing is set, an embed comment that is not followed by a code block
+// yields an empty block without fences (see MarkdownFormat.CodeBlocks).
+func scanMarkdown(source string, missing bool) []CodeBlock {
+	scanner := markdownScanner{missing: missing}
+	for offset := 0; offset < len(source); {
+		end := strings.IndexByte(source[offset:], '\n')
+		if end < 0 {
+			end = len(source)
+		} else {
+			end += offset + 1
+		}
+		scanner.scan(source[offset:end])
+		offset = end
+	}
+	scanner.finish()
+	return scanner.blocks
+}
+
+// markdownContainer is a block quote or list item that contains other blocks
+type markdownContainer struct {
+	quote bool
+	// width is the content indentation of list items
+	width int
+}
+
+// markdownFence is an open fenced code block
+type markdownFence struct {
+	marker string
+	depth  int
+	indent string
+	info   string
+	// offset of the opening fence marker
+	markerStart int
+	start       int
+	startLine   int
+	directive   Directive
+}
+
+// markdownScanner scans a markdown document line by line
+type markdownScanner struct {
+	// missing adds empty blocks for embed comments without a code block
+	missing bool
+	// offset of the next line
+	offset int
+	// number of the current line
+	line        int
+	containers  []markdownContainer
+	fence       *markdownFence
+	code        strings.Builder
+	htmlComment bool
+	directive   Directive
+	// end offset, line number and indentation of the last directive
+	directiveEnd    int
+	directiveLine   int
+	directiveIndent string
+	blocks          []CodeBlock
+}
+
+// scan scans the next line including its line ending
+func (s *markdownScanner) scan(raw string) {
+	s.line++
+	offset := s.offset
+	s.offset += len(raw)
+	line := strings.TrimSuffix(strings.TrimSuffix(raw, "\n"), "\r")
+	s.scanLine(line, raw, offset)
+}
+
+// scanLine scans a single line that starts at offset
+//
+// Positions in the line are tracked in bytes and columns, since a tab that is
+// partially consumed by a container leaves the remaining columns as indentation.
+func (s *markdownScanner) scanLine(line string, raw string, offset int) {
+	pos, col, matched := s.matchContainers(line)
+
+	if s.fence != nil {
+		if matched >= s.fence.depth {
+			if closing := s.closingFence(line, pos, col); closing != "" {
+				marker, _ := skipIndent(line, pos, col)
+				s.code.WriteString(line[:marker])
+				s.closeFence(offset+marker, closing)
+			} else {
+				s.code.WriteString(raw)
+			}
+			return
+		}
+		// the container of the fence was closed
+		s.fence = nil
+	}
+	s.containers = s.containers[:matched]
+
+	if s.htmlComment {
+		if strings.Contains(line, "-->") {
+			s.htmlComment = false
+		}
+		return
+	}
+
+	pos, col = s.openContainers(line, pos, col)
+	rest := line[pos:]
+	end, endCol := skipIndent(line, pos, col)
+	indent := endCol - col
+	switch {
+	case strings.TrimSpace(rest) == "":
+		return
+	case indent > 3:
+		// indented code or paragraph continuation
+	case s.parseDirective(rest, lineIndent(line, end)):
+		return
+	case strings.HasPrefix(line[end:], "<!--"):
+		s.htmlComment = !strings.Contains(rest, "-->")
+	default:
+		if fence := s.openFence(line[end:]); fence != nil {
+			fence.indent = lineIndent(line, end)
+			fence.markerStart = offset + end
+			fence.start = s.offset
+			fence.startLine = s.line + 1
+			s.fence = fence
+			s.code.Reset()
+			return
+		}
+	}
+	s.finish()
+}
+
+// parseDirective updates the pending directive if a line is a directive
+//
+// An embed comment finishes the pending embed comment, which is not followed by a code block.
+func (s *markdownScanner) parseDirective(line string, indent string) bool {
+	directive, ok := MarkdownFormat{}.ParseDirective(line)
+	if !ok {
+		return false
+	}
+	if directive.Command != "" && s.directive.Command != "" {
+		s.finish()
+	}
+	s.directive.merge(directive)
+	s.directiveEnd = s.offset
+	s.directiveLine = s.line
+	s.directiveIndent = indent
+	return true
+}
+
+// finish adds a missing code block for a pending embed comment
+func (s *markdownScanner) finish() {
+	if s.missing && s.directive.Command != "" && !s.directive.IgnoreNext {
+		s.blocks = append(s.blocks, CodeBlock{
+			FenceStart:   s.directiveEnd,
+			Start:        s.directiveEnd,
+			End:          s.directiveEnd,
+			StartLine:    s.directiveLine + 1,
+			EndLine:      s.directiveLine + 1,
+			Indent:       s.directiveIndent,
+			EmbedComment: s.directive.Command,
+		})
+	}
+	s.directive = Directive{}
+}
+
+// matchContainers matches the open containers against the start of a line
+//
+// It returns the position and column after the matched containers
+// and the number of matched containers.
+func (s *markdownScanner) matchContainers(line string) (int, int, int) {
+	pos, col := 0, 0
+	for i, container := range s.containers {
+		end, endCol := skipIndent(line, pos, col)
+		indent := endCol - col
+		if container.quote {
+			if indent > 3 || end >= len(line) || line[end] != '>' {
+				return pos, col, i
+			}
+			pos, col = blockQuoteMarker(line, end, endCol)
+			continue
+		}
+		if strings.TrimSpace(line[pos:]) == "" {
+			pos, col = advanceColumns(line, pos, col, container.width)
+			continue
+		}
+		if indent < container.width {
+			return pos, col, i
+		}
+		pos, col = advanceColumns(line, pos, col, container.width)
+	}
+	return pos, col, len(s.containers)
+}
+
+// openContainers opens new block quotes and list items at the start of a line
+func (s *markdownScanner) openContainers(line string, pos, col int) (int, int) {
+	for {
+		end, endCol := skipIndent(line, pos, col)
+		indent := endCol - col
+		if indent > 3 || end >= len(line) {
+			return pos, col
+		}
+		if line[end] == '>' {
+			s.containers = append(s.containers, markdownContainer{quote: true})
+			pos, col = blockQuoteMarker(line, end, endCol)
+			continue
+		}
+		if thematicBreakRegex.MatchString(line[end:]) {
+			return pos, col
+		}
+		marker := listMarkerRegex.FindStringSubmatch(line[end:])
+		if marker == nil {
+			return pos, col
+		}
+		markerEnd, markerCol := end+len(marker[1]), endCol+len(marker[1])
+		_, contentCol := skipIndent(line, markerEnd, markerCol)
+		width := indent + len(marker[1]) + contentCol - markerCol
+		if spaces := contentCol - markerCol; spaces == 0 || spaces > 4 {
+			// content starts after a single space
+			width = indent + len(marker[1]) + 1
+		}
+		s.containers = append(s.containers, markdownContainer{width: width})
+		pos, col = advanceColumns(line, pos, col, width)
+	}
+}
+
+// openFence checks if a line (without indentation) opens a fenced code block
+func (s *markdownScanner) openFence(line string) *markdownFence {
+	match := openingFenceRegex.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	info := strings.TrimSpace(match[2])
+	if match[1][0] == '`' && strings.Contains(info, "`") {
+		// inline code span
+		return nil
+	}
+	fence := &markdownFence{
+		marker:    match[1],
+		depth:     len(s.containers),
+		info:      info,
+		directive: s.directive,
+	}
+	s.directive = Directive{}
+	return fence
+}
+
+// closingFence returns the closing fence marker if a line closes the open fence
+//
+// The container prefixes end at pos, which is at column col.
+func (s *markdownScanner) closingFence(line string, pos, col int) string {
+	end, endCol := skipIndent(line, pos, col)
+	if endCol-col > 3 {
+		return ""
+	}
+	line = line[end:]
+	length := len(line) - len(strings.TrimLeft(line, s.fence.marker[:1]))
+	if length < len(s.fence.marker) || strings.TrimSpace(line[length:]) != "" {
+		return ""
+	}
+	return line[:length]
+}
+
+// closeFence closes the open fence whose closing fence marker starts at end
+func (s *markdownScanner) closeFence(end int, closing string) {
+	fence := s.fence
+	s.fence = nil
+	language, attributes := parseInfoString(fence.info)
+	embedComment := fence.directive.Command
+	if embedComment == "" {
+		embedComment = infoStringDirective(attributes)
+	}
+	s.blocks = append(s.blocks, CodeBlock{
+		FenceStart:   fence.markerStart,
+		Fence:        fence.marker,
+		ClosingFence: closing,
+		Start:        fence.start,
+		End:          end,
+		StartLine:    fence.startLine,
+		EndLine:      s.line,
+		Indent:       fence.indent,
+		Code:         s.code.String(),
+		EmbedComment: embedComment,
+		Ignore:       fence.directive.IgnoreNext,
+		Language:     language,
+		Info:         fence.info,
+		Attributes:   attributes,
+	})
+}
+
+// skipIndent returns the position and column after the indentation at pos,
+// which is at column col
+//
+// Tabs advance to the next multiple of four columns. A tab at pos can already
+// be partially consumed, which is the case if col is not at its start.
+func skipIndent(line string, pos, col int) (int, int) {
+	for ; pos < len(line); pos++ {
+		switch line[pos] {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return pos, col
+		}
+	}
+	return pos, col
+}
+
+// advanceColumns advances the position and column at most n columns
+//
+// A tab that is wider than the remaining columns is partially consumed,
+// i.e. the position stays at the tab.
+func advanceColumns(line string, pos, col, n int) (int, int) {
+	target := col + n
+	for pos < len(line) && col < target {
+		next := col + 1
+		if line[pos] == '\t' {
+			next = col + 4 - col%4
+		}
+		if next > target {
+			return pos, target
+		}
+		pos, col = pos+1, next
+	}
+	return pos, col
+}
+
+// blockQuoteMarker returns the position and column after a block quote marker at pos
+//
+// The marker includes a single optional space, which can be part of a tab.
+func blockQuoteMarker(line string, pos, col int) (int, int) {
+	pos, col = pos+1, col+1
+	if pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+		return advanceColumns(line, pos, col, 1)
+	}
+	return pos, col
+}
+
+// lineIndent returns the indentation of the content of a line that starts at end
+//
+// List markers are replaced by spaces, whereas tabs and block quote markers are kept.
+func lineIndent(line string, end int) string {
+	return strings.Map(func(c rune) rune {
+		if c == '\t' || c == '>' {
+			return c
+		}
+		return ' '
+	}, line[:end])
+}
+
+// parseInfoString parses the info string of a fenced code block
+//
+// The language is the first word of the info string or, for Pandoc style
+// attributes (e.g. {.python .numberLines}), the first class.
+// Attributes are key=value pairs, which can also be enclosed in braces.
+func parseInfoString(info string) (LanguageID, map[string]string) {
+	var language string
+	if !strings.HasPrefix(info, "{") {
+		language = info
+		if end := strings.IndexAny(info, " \t{"); end >= 0 {
+			language = info[:end]
+		}
+		info = info[len(language):]
+	}
+
+	var attributes map[string]string
+	for _, token := range splitAttributes(info) {
+		switch {
+		case strings.HasPrefix(token, "."):
+			if language == "" {
+				language = token[1:]
+			}
+		case strings.HasPrefix(token, "#"):
+			attributes = setAttribute(attributes, "id", token[1:])
+		case strings.Contains(token, "="):
+			parts := strings.SplitN(token, "=", 2)
+			attributes = setAttribute(attributes, parts[0], unquote(parts[1]))
+		}
+	}
+	return LanguageID(language), attributes
+}
+
+// infoStringDirective returns the embed command given by the attributes of an info string
+//
+// The command is either given as embed="<command>" or as file=<path>
+// with optional lines=<start>-<end>.
+func infoStringDirective(attributes map[string]string) string {
+	if embed, ok := attributes["embed"]; ok {
+		return embed
+	}
+	file, ok := attributes["file"]
+	if !ok {
+		return ""
+	}
+	if lines, ok := attributes["lines"]; ok {
+		return file + "#L" + lines
+	}
+	return file
+}
+
+func setAttribute(attributes map[string]string, key string, value string) map[string]string {
+	if attributes == nil {
+		attributes = make(map[string]string)
+	}
+	attributes[key] = value
+	return attributes
+}
+
+// splitAttributes splits attributes at whitespace and braces outside of quotes
+func splitAttributes(attributes string) []string {
+	var tokens []string
+	var token strings.Builder
+	var quote rune
+	for _, c := range attributes {
+		switch {
+		case quote != 0:
+			token.WriteRune(c)
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			token.WriteRune(c)
+		case c == ' ' || c == '\t' || c == '{' || c == '}':
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(c)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,291 @@
+package embedme
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fencedBlock is the info string and content of a fenced code block
+type fencedBlock struct {
+	Info    string
+	Content string
+}
+
+func fencedBlocks(source string) []fencedBlock {
+	var blocks []fencedBlock
+	for _, block := range ExtractCodeBlocks(source) {
+		blocks = append(blocks, fencedBlock{Info: block.Info, Content: block.Content()})
+	}
+	return blocks
+}
+
+// TestCommonMarkFencedCodeBlocks tests the examples of the
+// fenced code blocks section of the CommonMark spec (version 0.30)
+//
+// Unlike the spec, fences that are never closed are not code blocks.
+func TestCommonMarkFencedCodeBlocks(t *testing.T) {
+	for _, c := range []struct {
+		example  int
+		source   string
+		expected []fencedBlock
+	}{
+		{119, "```\n<\n >\n```\n", []fencedBlock{{"", "<\n >\n"}}},
+		{120, "~~~\n<\n >\n~~~\n", []fencedBlock{{"", "<\n >\n"}}},
+		{121, "``\nfoo\n``\n", nil},
+		{122, "```\naaa\n~~~\n```\n", []fencedBlock{{"", "aaa\n~~~\n"}}},
+		{123, "~~~\naaa\n```\n~~~\n", []fencedBlock{{"", "aaa\n```\n"}}},
+		{124, "````\naaa\n```\n``````\n", []fencedBlock{{"", "aaa\n```\n"}}},
+		{125, "~~~~\naaa\n~~~\n~~~~\n", []fencedBlock{{"", "aaa\n~~~\n"}}},
+		{126, "```\n", nil},
+		{127, "`````\n\n```\naaa\n", nil},
+		{128, "> ```\n> aaa\n\nbbb\n", nil},
+		{129, "```\n\n  \n```\n", []fencedBlock{{"", "\n  \n"}}},
+		{130, "```\n```\n", []fencedBlock{{"", ""}}},
+		{131, " ```\n aaa\naaa\n```\n", []fencedBlock{{"", "aaa\naaa\n"}}},
+		{132, "  ```\naaa\n  aaa\naaa\n  ```\n", []fencedBlock{{"", "aaa\naaa\naaa\n"}}},
+		{133, "   ```\n   aaa\n    aaa\n  aaa\n   ```\n", []fencedBlock{{"", "aaa\n aaa\naaa\n"}}},
+		{134, "    ```\n    aaa\n    ```\n", nil},
+		{135, "```\naaa\n  ```\n", []fencedBlock{{"", "aaa\n"}}},
+		{136, "   ```\naaa\n  ```\n", []fencedBlock{{"", "aaa\n"}}},
+		{137, "```\naaa\n    ```\n", nil},
+		{138, "``` ```\naaa\n", nil},
+		{139, "~~~~~~\naaa\n~~~ ~~\n", nil},
+		{140, "foo\n```\nbar\n```\nbaz\n", []fencedBlock{{"", "bar\n"}}},
+		{141, "foo\n---\n~~~\nbar\n~~~\n# baz\n", []fencedBlock{{"", "bar\n"}}},
+		{142, "```ruby\ndef foo(x)\n  return 3\nend\n```\n", []fencedBlock{
+			{"ruby", "def foo(x)\n  return 3\nend\n"},
+		}},
+		{143, "~~~~    ruby startline=3 $%@#$\ndef foo(x)\n  return 3\nend\n~~~~~~~\n", []fencedBlock{
+			{"ruby startline=3 $%@#$", "def foo(x)\n  return 3\nend\n"},
+		}},
+		{144, "````;\n````\n", []fencedBlock{{";", ""}}},
+		{145, "``` aa ```\nfoo\n", nil},
+		{146, "~~~ aa ``` ~~~\nfoo\n~~~\n", []fencedBlock{{"aa ``` ~~~", "foo\n"}}},
+		{147, "```\n``` aaa\n```\n", []fencedBlock{{"", "``` aaa\n"}}},
+		// block quotes (example 237 and 238)
+		{237, "> ```\n> foo\n> ```\n", []fencedBlock{{"", "foo\n"}}},
+		{238, "> ```\nfoo\n```\n", nil},
+		// list items (example 255 and 273)
+		{255, "- foo\n\n  ```\n  bar\n  ```\n", []fencedBlock{{"", "bar\n"}}},
+		{273, "1. ```\n   foo\n   ```\n\n   bar\n", []fencedBlock{{"", "foo\n"}}},
+		// HTML comments (example 179 with a fence)
+		{179, "<!-- Foo\n\n```\nbar\n```\nbaz -->\nokay\n", nil},
+	} {
+		blocks := fencedBlocks(c.source)
+		equal := cmp.Equal(blocks, c.expected)
+		diff := cmp.Diff(blocks, c.expected)
+		if !equal {
+			t.Log(c.source)
+			t.Errorf("example %d: unexpected code blocks: %s", c.example, diff)
+		}
+	}
+}
+
+// TestCommonMarkTabs tests fenced code blocks in containers that are
+// indented with tabs, which are split like in the tabs section of the CommonMark spec
+func TestCommonMarkTabs(t *testing.T) {
+	for _, c := range []struct {
+		source   string
+		indent   string
+		expected []fencedBlock
+	}{
+		{"- item\n\n\t```go\n\tfoo\n\t```\n", "\t", []fencedBlock{{"go", "foo\n"}}},
+		{"-\titem\n\n\t```go\n\tfoo\n\t```\n", "\t", []fencedBlock{{"go", "foo\n"}}},
+		{"-\t```go\n\tfoo\n\t```\n", " \t", []fencedBlock{{"go", "foo\n"}}},
+		{"1. item\n\n\t ```go\n\t foo\n\t ```\n", "\t ", []fencedBlock{{"go", "foo\n"}}},
+		{">\t```go\n>\tfoo\n>\t```\n", ">\t", []fencedBlock{{"go", "foo\n"}}},
+		{"- item\n\n\t\t```go\n\t\tfoo\n\t\t```\n", "", nil},
+	} {
+		blocks := ExtractCodeBlocks(c.source)
+		if diff := cmp.Diff(fencedBlocks(c.source), c.expected); diff != "" {
+			t.Errorf("unexpected code blocks of %q: %s", c.source, diff)
+			continue
+		}
+		if len(blocks) > 0 && blocks[0].Indent != c.indent {
+			t.Errorf("expected indent %q of %q but got %q", c.indent, c.source, blocks[0].Indent)
+		}
+	}
+}
+
+func TestExtractCodeBlocksNested(t *testing.T) {
+	source := "" +
+		"> quote\n" +
+		"> ```go\n" +
+		">   // code.go\n" +
+		">\n" +
+		"> ```\n" +
+		"\n" +
+		"1. item\n" +
+		"   - nested\n" +
+		"\n" +
+		"     ~~~python\n" +
+		"     # code.py\n" +
+		"     ~~~\n"
+	blocks := ExtractCodeBlocks(source)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 code blocks, but got %d: %s", len(blocks), pretty(blocks))
+	}
+	for i, expected := range []struct {
+		indent   string
+		language LanguageID
+		content  string
+	}{
+		{"> ", "go", "  // code.go\n\n"},
+		{"     ", "python", "# code.py\n"},
+	} {
+		block := blocks[i]
+		if block.Indent != expected.indent {
+			t.Errorf("block %d: expected indent %q but got %q", i, expected.indent, block.Indent)
+		}
+		if block.Language != expected.language {
+			t.Errorf("block %d: expected language %q but got %q", i, expected.language, block.Language)
+		}
+		if block.Content() != expected.content {
+			t.Errorf("block %d: expected content %q but got %q", i, expected.content, block.Content())
+		}
+	}
+}
+
+func TestExtractCodeBlocksDirectives(t *testing.T) {
+	source := "" +
+		"<!-- embedme first.go -->\n" +
+		"```go\n" +
+		"```\n" +
+		"<!-- embedme second.go -->\n" +
+		"text between the comment and the block\n" +
+		"```go\n" +
+		"```\n" +
+		"<!-- embedme-ignore-next -->\n" +
+		"\n" +
+		"```go\n" +
+		"```\n"
+	// embed comments without a code block are ignored
+	extracted := ExtractCodeBlocks(source)
+	if len(extracted) != 3 {
+		t.Fatalf("expected 3 code blocks, but got %d: %s", len(extracted), pretty(extracted))
+	}
+	if extracted[1].EmbedComment != "" {
+		t.Errorf("expected no embed comment but got %q", extracted[1].EmbedComment)
+	}
+	if prose := ExtractCodeBlocks("<!-- embedme a.go -->\nSome prose here.\n"); len(prose) != 0 {
+		t.Errorf("expected no code blocks but got %s", pretty(prose))
+	}
+
+	blocks := MarkdownFormat{}.CodeBlocks(source)
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 code blocks, but got %d: %s", len(blocks), pretty(blocks))
+	}
+	if blocks[0].EmbedComment != "first.go" {
+		t.Errorf("expected embed comment %q but got %q", "first.go", blocks[0].EmbedComment)
+	}
+	// the second embed comment is not followed by a code block
+	created := CodeBlock{
+		FenceStart:   63,
+		Start:        63,
+		End:          63,
+		StartLine:    5,
+		EndLine:      5,
+		EmbedComment: "second.go",
+	}
+	if diff := cmp.Diff(blocks[1], created); diff != "" {
+		t.Errorf("unexpected missing code block: %s", diff)
+	}
+	if blocks[2].EmbedComment != "" {
+		t.Errorf("expected no embed comment but got %q", blocks[2].EmbedComment)
+	}
+	if !blocks[3].Ignore {
+		t.Errorf("expected block to be ignored")
+	}
+}
+
+func TestParseInfoString(t *testing.T) {
+	for _, c := range []struct {
+		info       string
+		language   LanguageID
+		attributes map[string]string
+	}{
+		{"", "", nil},
+		{"go", "go", nil},
+		{`go title="main.go" {1,3}`, "go", map[string]string{"title": "main.go"}},
+		{`go {embed="pkg/options.go#L3-12"}`, "go", map[string]string{"embed": "pkg/options.go#L3-12"}},
+		{`{.python .numberLines #greet startFrom=3}`, "python", map[string]string{
+			"id":        "greet",
+			"startFrom": "3",
+		}},
+		{`ruby startline=3 $%@#$`, "ruby", map[string]string{"startline": "3"}},
+	} {
+		language, attributes := parseInfoString(c.info)
+		if language != c.language {
+			t.Errorf("%q: expected language %q but got %q", c.info, c.language, language)
+		}
+		if diff := cmp.Diff(attributes, c.attributes); diff != "" {
+			t.Errorf("%q: unexpected attributes: %s", c.info, diff)
+		}
+	}
+}
+
+func TestInfoStringDirectives(t *testing.T) {
+	for _, c := range []struct {
+		info         string
+		embedComment string
+	}{
+		{"go", ""},
+		{`go title="main.go" {1,3}`, ""},
+		{"go file=pkg/options.go", "pkg/options.go"},
+		{"go file=pkg/options.go lines=3-12", "pkg/options.go#L3-12"},
+		{`go {embed="pkg/options.go#L3-12"}`, "pkg/options.go#L3-12"},
+		{`md {embed="go-struct pkg/options.go#Options"}`, "go-struct pkg/options.go#Options"},
+	} {
+		blocks := ExtractCodeBlocks("```" + c.info + "\n```\n")
+		if len(blocks) != 1 {
+			t.Fatalf("%q: expected 1 block but got %d", c.info, len(blocks))
+		}
+		if blocks[0].EmbedComment != c.embedComment {
+			t.Errorf("%q: expected embed comment %q but got %q", c.info, c.embedComment, blocks[0].EmbedComment)
+		}
+	}
+
+	// embed comments take precedence
+	blocks := ExtractCodeBlocks("<!-- embedme main.go -->\n```go file=other.go\n```\n")
+	if len(blocks) != 1 || blocks[0].EmbedComment != "main.go" {
+		t.Errorf("expected embed comment to take precedence over info string: %+v", blocks)
+	}
+}
+
+// syntheticMarkdown generates a markdown document of at least size bytes
+func syntheticMarkdown(size int) string {
+	var source strings.Builder
+	for i := 0; source.Len() < size; i++ {
+		fmt.Fprintf(&source, "## Section %d\n\nSome text that explains the code below.\n\n", i)
+		fmt.Fprintf(&source, "<!-- embedme code/section%d.go -->\n```go\n", i)
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&source, "fmt.Println(%d)\n", j)
+		}
+		source.WriteString("```\n\n")
+	}
+	return source.String()
+}
+
+func TestExtractCodeBlocksOfLargeDocuments(t *testing.T) {
+	source := syntheticMarkdown(64 * 1024)
+	blocks := ExtractCodeBlocks(source)
+	last := blocks[len(blocks)-1]
+	if line := strings.Count(source[:last.Start], "\n") + 1; last.StartLine != line {
+		t.Fatalf("expected last block to start in line %d but got %d", line, last.StartLine)
+	}
+}
+
+func BenchmarkExtractCodeBlocks(b *testing.B) {
+	for _, size := range []int{64 * 1024, 1024 * 1024, 8 * 1024 * 1024} {
+		source := syntheticMarkdown(size)
+		b.Run(fmt.Sprintf("%dKiB", size/1024), func(b *testing.B) {
+			b.SetBytes(int64(len(source)))
+			for i := 0; i < b.N; i++ {
+				ExtractCodeBlocks(source)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package embedme
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+)
+
+// Normalizer normalizes the lines of embedded code (e.g. dedent)
+type Normalizer string
+
+const (
+	// NormalizeDedent removes the common indentation of the lines
+	NormalizeDedent Normalizer = "dedent"
+	// NormalizeTrimTrailingWhitespace removes the trailing whitespace of the lines
+	NormalizeTrimTrailingWhitespace Normalizer = "trim_trailing_whitespace"
+	// NormalizeCollapseBlankLines replaces consecutive blank lines with a single blank line
+	NormalizeCollapseBlankLines Normalizer = "collapse_blank_lines"
+)
+
+var (
+	normalizers = map[Normalizer]func(lines []string) []string{
+		NormalizeDedent:                 internal.Dedent,
+		NormalizeTrimTrailingWhitespace: trimTrailingWhitespaceLines,
+		NormalizeCollapseBlankLines:     collapseBlankLines,
+	}
+)
+
+// ParseNormalizer parses the name of a normalizer
+func ParseNormalizer(name string) (Normalizer, error) {
+	normalizer := Normalizer(name)
+	if _, ok := normalizers[normalizer]; !ok {
+		var names []string
+		for known := range normalizers {
+			names = append(names, string(known))
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown normalizer %q (must be one of %v)", name, names)
+	}
+	return normalizer, nil
+}
+
+// ParseNormalizers parses the names of normalizers (see ParseNormalizer)
+func ParseNormalizers(names []string) ([]Normalizer, error) {
+	var parsed []Normalizer
+	for _, name := range names {
+		normalizer, err := ParseNormalizer(name)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, normalizer)
+	}
+	return parsed, nil
+}
+
+// Normalize normalizes the lines of embedded code
+func (n Normalizer) Normalize(lines []string) []string {
+	if normalize, ok := normalizers[n]; ok {
+		return normalize(lines)
+	}
+	return lines
+}
+
+func trimTrailingWhitespaceLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimRight(line, " \t")
+	}
+	return trimmed
+}
+
+func collapseBlankLines(lines []string) []string {
+	var collapsed []string
+	for i, line := range lines {
+		if i > 0 && strings.TrimSpace(line) == "" && strings.TrimSpace(lines[i-1]) == "" {
+			continue
+		}
+		collapsed = append(collapsed, line)
+	}
+	return collapsed
+}
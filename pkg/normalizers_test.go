@@ -0,0 +1,27 @@
+package embedme
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizers(t *testing.T) {
+	lines := []string{"\tif ok {  ", "", "", "\t\treturn", "\t}"}
+	for _, c := range []struct {
+		normalizer Normalizer
+		expected   []string
+	}{
+		{NormalizeDedent, []string{"if ok {  ", "", "", "\treturn", "}"}},
+		{NormalizeTrimTrailingWhitespace, []string{"\tif ok {", "", "", "\t\treturn", "\t}"}},
+		{NormalizeCollapseBlankLines, []string{"\tif ok {  ", "", "\t\treturn", "\t}"}},
+	} {
+		if diff := cmp.Diff(c.normalizer.Normalize(lines), c.expected); diff != "" {
+			t.Errorf("%s: unexpected lines: %s", c.normalizer, diff)
+		}
+	}
+
+	if _, err := ParseNormalizer("unknown"); err == nil {
+		t.Errorf("expected unknown normalizer to be invalid")
+	}
+}
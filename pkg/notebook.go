@@ -0,0 +1,343 @@
+package embedme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/romnn/embedme/internal"
+)
+
+// NotebookFormat is the format of Jupyter notebooks (.ipynb)
+//
+// The cells of a notebook are nested documents: fenced code blocks in markdown
+// cells are embedded like in markdown documents and code cells that start with
+// an embed comment (e.g. # embedme path#L1-20) are replaced with the embedded code.
+// Only the sources of changed cells are rewritten, so the metadata and outputs
+// of the notebook are preserved byte by byte.
+type NotebookFormat struct {
+	// Languages are the languages of code cells (the known languages by default)
+	Languages *LanguageRegistry
+}
+
+// Name ...
+func (NotebookFormat) Name() string {
+	return "notebook"
+}
+
+// ParseDirective parses # embedme path comments in the first line of python code cells
+func (NotebookFormat) ParseDirective(line string) (Directive, bool) {
+	return notebookCellFormat{Language: "py"}.ParseDirective(line)
+}
+
+// CodeBlocks returns no code blocks, the code blocks are in the cells of the notebook
+func (NotebookFormat) CodeBlocks(source string) []CodeBlock {
+	return nil
+}
+
+// Replace ...
+func (NotebookFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	return block.Start, block.End, code
+}
+
+// notebookMetadata is the metadata of a notebook that determines the language of code cells
+type notebookMetadata struct {
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+}
+
+// language returns the language of the code cells
+func (m notebookMetadata) language() LanguageID {
+	if name := m.Metadata.LanguageInfo.Name; name != "" {
+		return sourceLanguage(name)
+	}
+	if language := m.Metadata.KernelSpec.Language; language != "" {
+		return sourceLanguage(language)
+	}
+	return "py"
+}
+
+// Documents returns the sources of the markdown and code cells of a notebook
+func (f NotebookFormat) Documents(source string) ([]NestedDocument, error) {
+	var metadata notebookMetadata
+	if err := json.Unmarshal([]byte(source), &metadata); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %v", err)
+	}
+	scanner := notebookScanner{
+		decoder: json.NewDecoder(strings.NewReader(source)),
+		code:    notebookCellFormat{Language: metadata.language(), Languages: f.Languages},
+	}
+	if err := scanner.scan(); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %v", err)
+	}
+	return scanner.documents, nil
+}
+
+// EncodeDocument encodes the source of a cell in the style of the notebook
+//
+// Sources that are split into lines stay split into lines
+// with the same indentation.
+func (NotebookFormat) EncodeDocument(source string, document *NestedDocument, embedded string) string {
+	raw := source[document.Start:document.End]
+	if strings.HasPrefix(raw, `"`) {
+		return encodeJSONString(embedded)
+	}
+	lines := internal.Lines(embedded)
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	}
+	if len(lines) == 0 {
+		return "[]"
+	}
+
+	newline := "\n"
+	if strings.Contains(source, "\r\n") {
+		newline = "\r\n"
+	}
+	indent, closingIndent := notebookIndentation(source, document.Start, raw)
+	var encoded strings.Builder
+	encoded.WriteString("[")
+	for i, line := range lines {
+		if i > 0 {
+			encoded.WriteString(",")
+		}
+		if i < len(lines)-1 {
+			line += "\n"
+		}
+		encoded.WriteString(newline + indent + encodeJSONString(line))
+	}
+	encoded.WriteString(newline + closingIndent + "]")
+	return encoded.String()
+}
+
+// notebookIndentation returns the indentation of the lines and the closing bracket of a source array
+func notebookIndentation(source string, start int, raw string) (string, string) {
+	if first := strings.IndexByte(raw, '\n'); first >= 0 {
+		last := strings.LastIndexByte(raw, '\n')
+		indent := raw[first+1:]
+		indent = indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+		closingIndent := strings.TrimRight(raw[last+1:], "]")
+		return indent, closingIndent
+	}
+	// the array was empty, indent relative to the "source" key
+	line := source[strings.LastIndexByte(source[:start], '\n')+1:]
+	keyIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	return keyIndent + " ", keyIndent
+}
+
+// encodeJSONString encodes a string like Jupyter without escaping HTML characters
+func encodeJSONString(s string) string {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(encoded.String(), "\n")
+}
+
+// notebookScanner finds the sources of the cells of a notebook
+type notebookScanner struct {
+	decoder   *json.Decoder
+	code      notebookCellFormat
+	documents []NestedDocument
+}
+
+func (s *notebookScanner) scan() error {
+	return s.object(func(key string) error {
+		if key != "cells" {
+			return s.skip()
+		}
+		return s.array(s.cell)
+	})
+}
+
+// cell scans a cell and adds its source if it is a markdown or code cell
+func (s *notebookScanner) cell() error {
+	var cellType string
+	document := NestedDocument{Start: -1}
+	err := s.object(func(key string) error {
+		switch key {
+		case "cell_type":
+			return s.decoder.Decode(&cellType)
+		case "source":
+			return s.cellSource(&document)
+		}
+		return s.skip()
+	})
+	if err != nil || document.Start < 0 {
+		return err
+	}
+	switch cellType {
+	case "markdown":
+		document.Format = MarkdownFormat{}
+	case "code":
+		document.Format = s.code
+	default:
+		return nil
+	}
+	s.documents = append(s.documents, document)
+	return nil
+}
+
+// cellSource decodes the source of a cell, which is a string or a list of lines
+func (s *notebookScanner) cellSource(document *NestedDocument) error {
+	var raw json.RawMessage
+	if err := s.decoder.Decode(&raw); err != nil {
+		return err
+	}
+	document.End = int(s.decoder.InputOffset())
+	document.Start = document.End - len(raw)
+
+	var lines []string
+	if err := json.Unmarshal(raw, &document.Source); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return fmt.Errorf("cell source must be a string or a list of strings")
+	}
+	document.Source = strings.Join(lines, "")
+	return nil
+}
+
+// object scans an object and calls value for each key, which must consume the value
+func (s *notebookScanner) object(value func(key string) error) error {
+	if err := s.delim('{'); err != nil {
+		return err
+	}
+	for s.decoder.More() {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err := value(token.(string)); err != nil {
+			return err
+		}
+	}
+	return s.delim('}')
+}
+
+// array scans an array and calls value for each element, which must consume the element
+func (s *notebookScanner) array(value func() error) error {
+	if err := s.delim('['); err != nil {
+		return err
+	}
+	for s.decoder.More() {
+		if err := value(); err != nil {
+			return err
+		}
+	}
+	return s.delim(']')
+}
+
+func (s *notebookScanner) delim(expected json.Delim) error {
+	token, err := s.decoder.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("expected %s at offset %d", expected, s.decoder.InputOffset())
+	}
+	return nil
+}
+
+func (s *notebookScanner) skip() error {
+	var raw json.RawMessage
+	return s.decoder.Decode(&raw)
+}
+
+// notebookCellFormat is the format of notebook code cells
+//
+// A code cell is an embed target if its first line is an embed comment.
+type notebookCellFormat struct {
+	Language  LanguageID
+	Languages *LanguageRegistry
+}
+
+// Name ...
+func (notebookCellFormat) Name() string {
+	return "notebook-cell"
+}
+
+// notebookCellRegexes are the expressions for the ignore and embed comments of a cell
+type notebookCellRegexes struct {
+	ignore  *regexp.Regexp
+	command *regexp.Regexp
+}
+
+var (
+	// notebookCellRegexesForComment caches the cell expressions for line comments
+	notebookCellRegexesForComment sync.Map
+)
+
+func cellRegexes(comment string) notebookCellRegexes {
+	if regexes, ok := notebookCellRegexesForComment.Load(comment); ok {
+		return regexes.(notebookCellRegexes)
+	}
+	quoted := regexp.QuoteMeta(comment)
+	regexes := notebookCellRegexes{
+		ignore:  regexp.MustCompile(`^\s*` + quoted + `\s*embedme[ -]ignore-(next|start|end|file)\s*$`),
+		command: regexp.MustCompile(`^\s*` + quoted + `\s*embedme\s+(.+?)\s*$`),
+	}
+	notebookCellRegexesForComment.Store(comment, regexes)
+	return regexes
+}
+
+// ParseDirective parses an embed comment using the line comment of the language (e.g. # embedme path)
+func (f notebookCellFormat) ParseDirective(line string) (Directive, bool) {
+	comment := "#"
+	if lang, ok := registryOrDefault(f.Languages).Lookup(f.Language); ok && lang.LineComment != "" {
+		comment = lang.LineComment
+	}
+	regexes := cellRegexes(comment)
+	return parseDirective(line, regexes.ignore, regexes.command)
+}
+
+// CodeBlocks returns the code after the embed comment in the first line of a cell
+func (f notebookCellFormat) CodeBlocks(source string) []CodeBlock {
+	start := len(source)
+	if end := strings.IndexByte(source, '\n'); end >= 0 {
+		start = end + 1
+	}
+	directive, ok := f.ParseDirective(strings.TrimSuffix(source[:start], "\n"))
+	if !ok || directive.Command == "" {
+		return nil
+	}
+	code := source[start:]
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		// cells do not end with a newline
+		code += "\n"
+	}
+	return []CodeBlock{{
+		Start:        start,
+		End:          len(source),
+		StartLine:    2,
+		EndLine:      len(internal.Lines(source)),
+		Code:         code,
+		EmbedComment: directive.Command,
+		Language:     f.Language,
+	}}
+}
+
+// Replace replaces the code of a cell without a trailing newline
+func (notebookCellFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	if code == block.Code {
+		return block.Start, block.End, source[block.Start:block.End]
+	}
+	code = strings.TrimRight(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	if block.Start == len(source) && !strings.HasSuffix(source, "\n") {
+		code = "\n" + code
+	}
+	return block.Start, block.End, code
+}
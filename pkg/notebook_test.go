@@ -0,0 +1,189 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func notebook(source string) string {
+	return strings.ReplaceAll(strings.TrimSpace(source), "FENCE", "```") + "\n"
+}
+
+func TestEmbedNotebook(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/examples/greet.py", []byte("def greet(name):\n    print(f\"<{name}>\")\n\ngreet(\"world\")\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/examples/setup.sh", []byte("pip install embedme\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	source := notebook(`
+{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Setup\n",
+    "\n",
+    "<!-- embedme examples/setup.sh -->\n",
+    "FENCEbash\n",
+    "FENCE"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {"tags":  ["keep"]},
+   "outputs": [
+    {
+     "name": "stdout",
+     "output_type": "stream",
+     "text": ["<world>\n"]
+    }
+   ],
+   "source": [
+    "# embedme examples/greet.py#L0-2\n",
+    "def greet(name):\n",
+    "    print(name)"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": "# embedme examples/greet.py#L3-4"
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": []
+  }
+ ],
+ "metadata": {
+  "kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"}
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+	`)
+
+	expected := notebook(`
+{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Setup\n",
+    "\n",
+    "<!-- embedme examples/setup.sh -->\n",
+    "FENCEbash\n",
+    "pip install embedme\n",
+    "FENCE"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {"tags":  ["keep"]},
+   "outputs": [
+    {
+     "name": "stdout",
+     "output_type": "stream",
+     "text": ["<world>\n"]
+    }
+   ],
+   "source": [
+    "# embedme examples/greet.py#L0-2\n",
+    "def greet(name):\n",
+    "    print(f\"<{name}>\")"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": "# embedme examples/greet.py#L3-4\ngreet(\"world\")"
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": []
+  }
+ ],
+ "metadata": {
+  "kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"}
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+	`)
+
+	path := filepath.Join(workingDir, "guide.ipynb")
+	embedded, err := embedder.Embed([]byte(source), path, "guide.ipynb")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded notebook: %s", diff)
+	}
+
+	// embedding again must not change the notebook
+	reembedded, err := embedder.Embed([]byte(embedded), path, "guide.ipynb")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+
+	if _, err := embedder.Embed([]byte(`{"cells": [`), path, "guide.ipynb"); err == nil {
+		t.Fatalf("expected invalid notebook to fail")
+	}
+}
+
+func TestEncodeNotebookCell(t *testing.T) {
+	source := "{\r\n \"cells\": [{\"cell_type\": \"code\", \"source\": []}]\r\n}\r\n"
+	format := NotebookFormat{}
+	documents, err := format.Documents(source)
+	if err != nil {
+		t.Fatalf("failed to find cells: %v", err)
+	}
+	if len(documents) != 1 {
+		t.Fatalf("expected 1 cell but got %d", len(documents))
+	}
+	encoded := format.EncodeDocument(source, &documents[0], "a\nb & <c>\n")
+	expected := "[\r\n  \"a\\n\",\r\n  \"b & <c>\"\r\n ]"
+	if diff := cmp.Diff(encoded, expected); diff != "" {
+		t.Fatalf("unexpected encoded cell: %s", diff)
+	}
+}
+
+func TestNotebookCellLanguages(t *testing.T) {
+	languages := Languages.With(Language{Name: "Python", Aliases: []LanguageID{"py"}, LineComment: "//"})
+	format := notebookCellFormat{Language: "py", Languages: languages}
+	if directive, ok := format.ParseDirective("// embedme main.py"); !ok || directive.Command != "main.py" {
+		t.Errorf("expected the embed comment of the overridden language but got %+v", directive)
+	}
+	if _, ok := format.ParseDirective("# embedme main.py"); ok {
+		t.Errorf("expected the comment of the known language to be ignored")
+	}
+}
@@ -2,22 +2,55 @@ package embedme
 
 // Options for embedme
 type Options struct {
+	// Remove the embed comment from the code block (only works with Stdout)
 	StripEmbedComment bool
-	Stdout            bool
-	Verify            bool
-	DryRun            bool
-	WorkingDir        string
-	Base              string
+	// Remove AsciiDoc callouts (e.g. // <1>) from code embedded into documents of other formats
+	StripCallouts bool
+	// Output the resulting document to stdout without writing
+	Stdout bool
+	// Verify that running embedme would result in no changes
+	Verify bool
+	// Run embedme as usual, but don't write
+	DryRun bool
+	// Directory to run embedme from
+	WorkingDir string
+	// Source files directory prefix for shorter code block comments
+	Base string
+	// Additional directories that embedded files are resolved in
+	BaseDirs []string
+	// Document format (e.g. markdown or rst), detected from the file extension by default
+	Format string
+	// Additional languages, which override known languages with the same aliases
+	Languages []Language
+	// Only embed into the code blocks that match one of the selectors
+	OnlyBlocks []BlockSelector
+	// Do not embed into the code blocks that match one of the selectors
+	SkipBlocks []BlockSelector
+	// Override the options for the documents that match path patterns
+	Overrides []OptionsOverride
+	// Refuse to embed the output of commands (e.g. $ ls)
+	DenyCommands bool
+	// Normalize the embedded code in order (e.g. dedent)
+	Normalizers []Normalizer
 }
 
 // NewDefaultOptions returns default options for embedme
 func NewDefaultOptions() Options {
 	return Options{
 		StripEmbedComment: false,
+		StripCallouts:     false,
 		Stdout:            false,
 		Verify:            false,
 		DryRun:            false,
 		WorkingDir:        "",
 		Base:              "",
+		BaseDirs:          nil,
+		Format:            "",
+		Languages:         nil,
+		OnlyBlocks:        nil,
+		SkipBlocks:        nil,
+		Overrides:         nil,
+		DenyCommands:      false,
+		Normalizers:       nil,
 	}
 }
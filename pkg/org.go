@@ -0,0 +1,119 @@
+package embedme
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches the start of a source block and its language and header arguments
+	orgBeginRegex = regexp.MustCompile(`(?i)^\s*#\+begin_src(\s+(\S+))?(.*)$`)
+	// Matches the end of a source block
+	orgEndRegex = regexp.MustCompile(`(?i)^\s*#\+end_src\s*$`)
+	// Matches an affiliated keyword (e.g. #+NAME:) that precedes a block
+	orgKeywordRegex = regexp.MustCompile(`^\s*#\+\w+(\[[^\]]*\])?:`)
+	// Matches an embed comment
+	orgEmbedCommentRegex = regexp.MustCompile(`^\s*#\s+embedme\s+(.+?)\s*$`)
+	// Matches an ignore next comment
+	orgIgnoreRegex = regexp.MustCompile(`^\s*#\s+embedme[ -]ignore-(next|start|end|file)\s*$`)
+	// Matches a line that must be escaped with a comma inside a block
+	orgEscapeRegex = regexp.MustCompile(`^([ \t]*)(,*(\*|#\+))`)
+)
+
+// ExtractOrgBlocks extracts the source blocks of an Org document
+//
+// An embed comment (# embedme path) or ignore next comment (# embedme-ignore-next)
+// applies to the next source block. Without an embed comment, the command can
+// also be given by the :embed header argument, e.g. #+BEGIN_SRC go :embed main.go.
+func ExtractOrgBlocks(source string) []CodeBlock {
+	lines := sourceLines(source)
+	var blocks []CodeBlock
+	var directive Directive
+	for i := 0; i < len(lines); i++ {
+		text := lines[i].text
+		switch {
+		case lines[i].blank(), orgKeywordRegex.MatchString(text):
+		case directive.update(OrgFormat{}, text):
+		case orgBeginRegex.MatchString(text):
+			end := i + 1
+			for end < len(lines) && !orgEndRegex.MatchString(lines[end].text) {
+				end++
+			}
+			if end == len(lines) {
+				return blocks
+			}
+			blocks = append(blocks, orgBlock(source, lines, i, end, directive))
+			directive = Directive{}
+			i = end
+		default:
+			directive = Directive{}
+		}
+	}
+	return blocks
+}
+
+func orgBlock(source string, lines []sourceLine, begin int, end int, directive Directive) CodeBlock {
+	match := orgBeginRegex.FindStringSubmatch(lines[begin].text)
+	block := enclosedBlock(source, lines, begin, end)
+	block.Language = sourceLanguage(match[2])
+	block.Info = strings.TrimSpace(match[2] + match[3])
+	block.Attributes = parseOrgHeaderArguments(match[3])
+	block.EmbedComment = directive.Command
+	if block.EmbedComment == "" {
+		block.EmbedComment = block.Attributes["embed"]
+	}
+	block.Ignore = directive.IgnoreNext
+	return block
+}
+
+// parseOrgHeaderArguments parses header arguments (e.g. :results output :embed main.go)
+func parseOrgHeaderArguments(arguments string) map[string]string {
+	var attributes map[string]string
+	key := ""
+	var values []string
+	for _, field := range append(strings.Fields(arguments), ":") {
+		if !strings.HasPrefix(field, ":") {
+			values = append(values, field)
+			continue
+		}
+		if key != "" {
+			attributes = setAttribute(attributes, key, strings.Join(values, " "))
+		}
+		key, values = field[1:], nil
+	}
+	return attributes
+}
+
+// OrgFormat is the format of Org documents
+type OrgFormat struct{}
+
+// Name ...
+func (OrgFormat) Name() string {
+	return "org"
+}
+
+// ParseDirective parses # embedme path and # embedme-ignore-... comments
+func (OrgFormat) ParseDirective(line string) (Directive, bool) {
+	return parseDirective(line, orgIgnoreRegex, orgEmbedCommentRegex)
+}
+
+// CodeBlocks ...
+func (OrgFormat) CodeBlocks(source string) []CodeBlock {
+	return ExtractOrgBlocks(source)
+}
+
+// Replace ...
+func (OrgFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	return block.Start, block.End, code
+}
+
+// Escape prefixes lines that start with * or #+ with a comma
+//
+// Lines that are already escaped get an additional comma.
+func (OrgFormat) Escape(block *CodeBlock, lines []string) ([]string, error) {
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = orgEscapeRegex.ReplaceAllString(line, "$1,$2")
+	}
+	return escaped, nil
+}
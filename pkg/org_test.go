@@ -0,0 +1,115 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestExtractOrgBlocks(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		source      string
+		expected    []documentBlock
+	}{
+		{
+			description: "source block",
+			source:      "#+BEGIN_SRC go\nfunc main() {}\n#+END_SRC\n",
+			expected:    []documentBlock{{"go", "", false, "func main() {}\n"}},
+		},
+		{
+			description: "embed comment, keywords and lower case",
+			source:      "# embedme main.py\n\n#+NAME: greet\n#+begin_src python :results output\n  print(1)\n#+end_src\n",
+			expected:    []documentBlock{{"python", "main.py", false, "  print(1)\n"}},
+		},
+		{
+			description: "indented block with header argument",
+			source:      "- item\n  #+BEGIN_SRC C++ :embed main.cpp :exports both\n  a\n  #+END_SRC\n",
+			expected:    []documentBlock{{"cpp", "main.cpp", false, "a\n"}},
+		},
+		{
+			description: "ignore next comment",
+			source:      "# embedme-ignore-next\n#+BEGIN_SRC go\n#+END_SRC\n",
+			expected:    []documentBlock{{"go", "", true, ""}},
+		},
+		{
+			description: "embed comment only applies to the next block",
+			source:      "# embedme main.go\nText\n#+BEGIN_SRC go\n#+END_SRC\n#+BEGIN_SRC go\n",
+			expected:    []documentBlock{{"go", "", false, ""}},
+		},
+	} {
+		var blocks []documentBlock
+		for _, block := range ExtractOrgBlocks(c.source) {
+			blocks = append(blocks, documentBlock{
+				Language:     block.Language,
+				EmbedComment: block.EmbedComment,
+				Ignore:       block.Ignore,
+				Content:      block.Content(),
+			})
+		}
+		if diff := cmp.Diff(blocks, c.expected); diff != "" {
+			t.Log(c.source)
+			t.Errorf("%s: unexpected code blocks: %s", c.description, diff)
+		}
+	}
+}
+
+func TestEmbedOrg(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/notes.org", []byte(strings.TrimSpace(`
+* Heading
+#+BEGIN_SRC sh
+,* escaped
+#+END_SRC
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+* Notes
+
+# embedme notes.org
+#+BEGIN_SRC org
+#+END_SRC
+	`) + "\n"
+
+	expected := strings.TrimSpace(`
+* Notes
+
+# embedme notes.org
+#+BEGIN_SRC org
+,* Heading
+,#+BEGIN_SRC sh
+,,* escaped
+,#+END_SRC
+#+END_SRC
+	`) + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.org"), "readme.org")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	// embedding again must not change the document
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "readme.org"), "readme.org")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
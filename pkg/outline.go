@@ -0,0 +1,56 @@
+package embedme
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Outliner condenses a source file into an outline of its declarations
+type Outliner interface {
+	// Outline returns the declarations of a source file with their bodies elided
+	Outline(path string, content []byte) ([]string, error)
+}
+
+var (
+	// Outliners are the registered outliners for each language
+	Outliners = map[LanguageID]Outliner{}
+)
+
+func init() {
+	RegisterOutliner(GoOutliner{}, Languages.Aliases("Go")...)
+}
+
+// RegisterOutliner registers an outliner for languages
+func RegisterOutliner(outliner Outliner, languages ...LanguageID) {
+	for _, lang := range languages {
+		Outliners[lang] = outliner
+	}
+}
+
+// languageForPath returns the language of a file based on its name or extension
+func languageForPath(path string) LanguageID {
+	return Languages.languageForPath(path)
+}
+
+// languageForPath returns the language of a file using the file names of the registry
+func (r *LanguageRegistry) languageForPath(path string) LanguageID {
+	name := filepath.Base(path)
+	if lang, ok := r.LookupFilename(name); ok {
+		return lang.ID()
+	}
+	if strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile") {
+		return "dockerfile"
+	}
+	return LanguageID(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// outline outlines a file using the outliner registered for its language
+func outline(path string, content []byte) ([]string, error) {
+	lang := languageForPath(path)
+	outliner, ok := Outliners[lang]
+	if !ok {
+		return nil, fmt.Errorf("no outliner for %s files (%s)", lang, path)
+	}
+	return outliner.Outline(path, content)
+}
@@ -0,0 +1,138 @@
+package embedme
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+)
+
+// PythonSymbolExtractor extracts symbols from Python source files
+//
+// The extractor uses the indentation of the source to find the end of a symbol.
+// Supported kinds are "def" and "class". Nested symbols are selected using
+// dotted names, e.g. "def:Client.greet".
+type PythonSymbolExtractor struct{}
+
+var (
+	pythonKinds = map[string]string{
+		"def":   `(async\s+)?def`,
+		"class": `class`,
+	}
+	pythonDecoratorRegex = regexp.MustCompile(`^\s*@`)
+)
+
+// Extract ...
+func (PythonSymbolExtractor) Extract(path string, content []byte, kind string, name string) ([]string, error) {
+	if _, ok := pythonKinds[kind]; !ok {
+		return nil, fmt.Errorf("unsupported Python selector %q (must be def or class)", kind+":"+name)
+	}
+	lines := internal.Lines(string(content))
+	start, end := 0, len(lines)
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		// enclosing symbols can be classes or functions
+		keyword := pythonKinds["class"] + "|" + pythonKinds["def"]
+		if i == len(parts)-1 {
+			keyword = pythonKinds[kind]
+		}
+		var ok bool
+		start, end, ok = findPythonBlock(lines, start, end, keyword, part)
+		if !ok {
+			return nil, symbolNotFound(path, kind, name)
+		}
+	}
+	return internal.Dedent(lines[start:end]), nil
+}
+
+// findPythonBlock finds the lines of a def or class block in lines[from:to]
+func findPythonBlock(lines []string, from int, to int, keyword string, name string) (int, int, bool) {
+	header := symbolHeaderRegex(`^([ \t]*)(`+keyword+`)\s+%s\b`, name)
+	for i := from; i < to; i++ {
+		match := header.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		indent := len(match[1])
+		start := i
+		for start > from && pythonDecoratorRegex.MatchString(lines[start-1]) {
+			start--
+		}
+		return start, pythonBlockEnd(lines, i, to, indent), true
+	}
+	return 0, 0, false
+}
+
+// pythonBlockEnd finds the end of the block starting with a header line
+func pythonBlockEnd(lines []string, header int, to int, indent int) int {
+	var scanner pythonScanner
+	// the header may span multiple lines
+	end := header
+	for end < to {
+		scanner.scan(lines[end])
+		end++
+		if scanner.depth <= 0 && scanner.quote == "" {
+			break
+		}
+	}
+	// the body consists of all lines that are indented further than the header
+	last := end
+	for i := end; i < to; i++ {
+		line := lines[i]
+		inString := scanner.quote != ""
+		scanner.scan(line)
+		if inString {
+			last = i + 1
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if internal.IndentWidth(line) <= indent {
+			break
+		}
+		last = i + 1
+	}
+	return last
+}
+
+// pythonScanner tracks brackets and strings while scanning Python source
+type pythonScanner struct {
+	depth int
+	quote string
+}
+
+func (s *pythonScanner) scan(line string) {
+	for i := 0; i < len(line); i++ {
+		if s.quote != "" {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.HasPrefix(line[i:], s.quote) {
+				i += len(s.quote) - 1
+				s.quote = ""
+			}
+			continue
+		}
+		switch c := line[i]; c {
+		case '#':
+			return
+		case '(', '[', '{':
+			s.depth++
+		case ')', ']', '}':
+			s.depth--
+		case '"', '\'':
+			s.quote = string(c)
+			if triple := strings.Repeat(string(c), 3); strings.HasPrefix(line[i:], triple) {
+				s.quote = triple
+				i += 2
+			}
+		}
+	}
+	if len(s.quote) == 1 {
+		// single quoted strings end with the line
+		s.quote = ""
+	}
+}
@@ -0,0 +1,118 @@
+package embedme
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RegionFormat is the format of regions in source and configuration files
+//
+// A region starts with an embedme-start comment and ends with an embedme-end
+// comment using the comment syntax of the language of the file, e.g.
+//
+//	# embedme-start examples/values.yaml
+//	# embedme-end
+//
+// The embedded code is indented like the start comment. Its language is
+// inferred from the embedded code instead of the language of the file.
+type RegionFormat struct {
+	Language LanguageID
+	// Languages are the languages of the format (the known languages by default)
+	Languages *LanguageRegistry
+}
+
+// Name ...
+func (RegionFormat) Name() string {
+	return "region"
+}
+
+// regionRegexes are the expressions for the start, end and ignore comments of a region
+type regionRegexes struct {
+	start  *regexp.Regexp
+	end    *regexp.Regexp
+	ignore *regexp.Regexp
+}
+
+var (
+	// regionRegexesForComment caches the region expressions for the delimiters of comments
+	regionRegexesForComment sync.Map
+)
+
+func (f RegionFormat) regexes() (regionRegexes, bool) {
+	lang, ok := registryOrDefault(f.Languages).Lookup(f.Language)
+	if !ok || !lang.HasComments() {
+		return regionRegexes{}, false
+	}
+	prefix, suffix := lang.CommentDelimiters()
+	if regexes, ok := regionRegexesForComment.Load([2]string{prefix, suffix}); ok {
+		return regexes.(regionRegexes), true
+	}
+	comment := func(content string) *regexp.Regexp {
+		return regexp.MustCompile(
+			`^\s*` + regexp.QuoteMeta(prefix) + `\s*` + content + `\s*` + regexp.QuoteMeta(suffix) + `\s*$`,
+		)
+	}
+	regexes := regionRegexes{
+		start:  comment(`embedme-start\s+(.+?)`),
+		end:    comment(`embedme-end`),
+		ignore: comment(`embedme[ -]ignore-(next|start|end|file)`),
+	}
+	regionRegexesForComment.Store([2]string{prefix, suffix}, regexes)
+	return regexes, true
+}
+
+// ParseDirective parses embedme-start and embedme-ignore-... comments
+func (f RegionFormat) ParseDirective(line string) (Directive, bool) {
+	regexes, ok := f.regexes()
+	if !ok {
+		return Directive{}, false
+	}
+	return parseDirective(line, regexes.ignore, regexes.start)
+}
+
+// CodeBlocks extracts the regions of a file
+//
+// Regions without an end comment are ignored.
+func (f RegionFormat) CodeBlocks(source string) []CodeBlock {
+	regexes, ok := f.regexes()
+	if !ok {
+		return nil
+	}
+	lines := sourceLines(source)
+	var blocks []CodeBlock
+	var directive Directive
+	for i := 0; i < len(lines); i++ {
+		if !directive.update(f, lines[i].text) {
+			directive = Directive{}
+			continue
+		}
+		if directive.Command == "" {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !regexes.end.MatchString(lines[end].text) {
+			end++
+		}
+		if end == len(lines) {
+			return blocks
+		}
+		block := enclosedBlock(source, lines, i, end)
+		block.EmbedComment = directive.Command
+		block.Ignore = directive.IgnoreNext
+		blocks = append(blocks, block)
+		directive = Directive{}
+		i = end
+	}
+	return blocks
+}
+
+// Replace removes the indentation of blank lines
+func (RegionFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	if code == block.Code {
+		return block.Start, block.End, code
+	}
+	// the end comment is indented like the start comment
+	code = trimBlankLines(strings.TrimSuffix(code, block.Indent)) + block.Indent
+	return block.Start, block.End, code
+}
@@ -0,0 +1,166 @@
+package embedme
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestRegionFormatForPath(t *testing.T) {
+	for path, expected := range map[string]LanguageID{
+		"values.yaml":           "yaml",
+		"config/app.toml":       "toml",
+		"Dockerfile":            "dockerfile",
+		"docker/Dockerfile.dev": "dockerfile",
+		"Makefile":              "makefile",
+		"index.html":            "html",
+	} {
+		format, ok := FormatForPath(path).(RegionFormat)
+		if !ok {
+			t.Errorf("%s: expected region format but got %s", path, FormatForPath(path).Name())
+			continue
+		}
+		if format.Language != expected {
+			t.Errorf("%s: expected language %q but got %q", path, expected, format.Language)
+		}
+	}
+	for _, path := range []string{"README", "notes.txt", "data.json"} {
+		if name := FormatForPath(path).Name(); name != "markdown" {
+			t.Errorf("%s: expected markdown format but got %s", path, name)
+		}
+	}
+}
+
+func TestEmbedRegions(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/examples/resources.yaml", []byte("limits:\n  cpu: 100m\n\n  memory: 128Mi\n"), 0644)
+	afero.WriteFile(fs, "/work/dir/scripts/install.sh", []byte("apt-get update\napt-get install -y curl\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	for _, c := range []struct {
+		path     string
+		source   string
+		expected string
+	}{
+		{
+			path: "values.yaml",
+			source: strings.TrimSpace(`
+app:
+  name: embedme
+  # embedme-start examples/resources.yaml
+  old: value
+  # embedme-end
+  # embedme-ignore-next
+  # embedme-start examples/resources.yaml
+  ignored: true
+  # embedme-end
+			`) + "\n",
+			expected: strings.TrimSpace(`
+app:
+  name: embedme
+  # embedme-start examples/resources.yaml
+  limits:
+    cpu: 100m
+
+    memory: 128Mi
+  # embedme-end
+  # embedme-ignore-next
+  # embedme-start examples/resources.yaml
+  ignored: true
+  # embedme-end
+			`) + "\n",
+		},
+		{
+			path: "Dockerfile",
+			source: strings.TrimSpace(`
+FROM debian
+RUN \
+# embedme-start scripts/install.sh
+# embedme-end
+			`) + "\n",
+			expected: strings.TrimSpace(`
+FROM debian
+RUN \
+# embedme-start scripts/install.sh
+apt-get update
+apt-get install -y curl
+# embedme-end
+			`) + "\n",
+		},
+		{
+			path:     "index.html",
+			source:   "<pre>\n  <!-- embedme-start scripts/install.sh -->\n  <!-- embedme-end -->\n</pre>\n",
+			expected: "<pre>\n  <!-- embedme-start scripts/install.sh -->\n  apt-get update\n  apt-get install -y curl\n  <!-- embedme-end -->\n</pre>\n",
+		},
+	} {
+		embedded, err := embedder.Embed([]byte(c.source), filepath.Join(workingDir, c.path), c.path)
+		if err != nil {
+			t.Fatalf("%s: failed to embed: %v", c.path, err)
+		}
+		if diff := cmp.Diff(embedded, c.expected); diff != "" {
+			t.Fatalf("%s: unexpected embedded file: %s", c.path, diff)
+		}
+
+		// embedding again must not change the file
+		reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, c.path), c.path)
+		if err != nil {
+			t.Fatalf("%s: failed to embed: %v", c.path, err)
+		}
+		if diff := cmp.Diff(reembedded, c.expected); diff != "" {
+			t.Fatalf("%s: embedding is not stable: %s", c.path, diff)
+		}
+	}
+
+	// the code of regions is not in the language of the file
+	if strings.Contains(output.String(), "does not match") {
+		t.Fatalf("unexpected language warning: %s", output.String())
+	}
+}
+
+func TestEmbedRegionsOfOptionsLanguages(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+	afero.WriteFile(fs, "/work/dir/value.txt", []byte("value\n"), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	options.Languages = []Language{
+		{Name: "Conf", Aliases: []LanguageID{"conf"}, LineComment: ";"},
+		{Name: "YAML", Aliases: []LanguageID{"yaml", "yml"}, LineComment: "//"},
+	}
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	for path, comment := range map[string]string{"app.conf": ";", "values.yaml": "//"} {
+		source := comment + " embedme-start value.txt\n" + comment + " embedme-end\n"
+		expected := comment + " embedme-start value.txt\nvalue\n" + comment + " embedme-end\n"
+		embedded, err := embedder.Embed([]byte(source), filepath.Join(workingDir, path), path)
+		if err != nil {
+			t.Fatalf("%s: failed to embed: %v", path, err)
+		}
+		if diff := cmp.Diff(embedded, expected); diff != "" {
+			t.Errorf("%s: unexpected embedded file: %s", path, diff)
+		}
+	}
+}
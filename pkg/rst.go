@@ -0,0 +1,220 @@
+package embedme
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/romnn/embedme/internal"
+)
+
+var (
+	// Matches a code block directive and its language
+	rstCodeBlockRegex = regexp.MustCompile(`^(\s*)\.\.\s+(code-block|sourcecode|code)::\s*(\S*)\s*$`)
+	// Matches a highlight directive that sets the language of literal blocks
+	rstHighlightRegex = regexp.MustCompile(`^\s*\.\.\s+highlight::\s*(\S+)\s*$`)
+	// Matches an embed comment
+	rstEmbedCommentRegex = regexp.MustCompile(`^\s*\.\.\s+embedme:{0,2}\s+(.+?)\s*$`)
+	// Matches an ignore next comment
+	rstIgnoreRegex = regexp.MustCompile(`^\s*\.\.\s+embedme([ -]|:{1,2}\s*)ignore-(next|start|end|file)\s*$`)
+	// Matches a directive option
+	rstOptionRegex = regexp.MustCompile(`^\s*:([\w-]+):\s*(.*?)\s*$`)
+)
+
+// ExtractReStructuredTextBlocks extracts the code blocks of a reStructuredText document
+//
+// Code blocks are code-block (or code and sourcecode) directives and literal
+// blocks that follow a paragraph ending with "::". The language of literal
+// blocks is set using the highlight directive.
+//
+// An embed comment (.. embedme: path) or ignore next comment (.. embedme-ignore-next)
+// applies to the next code block. Without an embed comment, the command can
+// also be given by the :embed: option of a code-block directive.
+func ExtractReStructuredTextBlocks(source string) []CodeBlock {
+	scanner := rstScanner{source: source, lines: sourceLines(source)}
+	scanner.scan()
+	return scanner.blocks
+}
+
+// ReStructuredTextFormat is the format of reStructuredText documents
+type ReStructuredTextFormat struct{}
+
+// Name ...
+func (ReStructuredTextFormat) Name() string {
+	return "rst"
+}
+
+// ParseDirective parses .. embedme: path and .. embedme-ignore-... comments
+func (ReStructuredTextFormat) ParseDirective(line string) (Directive, bool) {
+	return parseDirective(line, rstIgnoreRegex, rstEmbedCommentRegex)
+}
+
+// CodeBlocks ...
+func (ReStructuredTextFormat) CodeBlocks(source string) []CodeBlock {
+	return ExtractReStructuredTextBlocks(source)
+}
+
+// Replace separates the code of empty blocks from their directive
+func (ReStructuredTextFormat) Replace(source string, block *CodeBlock, code string) (int, int, string) {
+	if code == block.Code {
+		return block.Start, block.End, code
+	}
+	code = trimBlankLines(strings.TrimSuffix(code, block.Indent))
+	if block.Code == "" {
+		// separate the new content from the directive
+		newline := internal.DetectNewline([]byte(source))
+		if !strings.HasSuffix(source[:block.Start], "\n") {
+			code = newline + code
+		}
+		code = newline + code
+	}
+	return block.Start, block.End, code
+}
+
+// rstScanner scans a reStructuredText document line by line
+type rstScanner struct {
+	source    string
+	lines     []sourceLine
+	highlight LanguageID
+	directive Directive
+	blocks    []CodeBlock
+}
+
+func (s *rstScanner) scan() {
+	for i := 0; i < len(s.lines); i++ {
+		text := s.lines[i].text
+		switch {
+		case s.lines[i].blank():
+		case s.directive.update(ReStructuredTextFormat{}, text):
+		case rstHighlightRegex.MatchString(text):
+			s.highlight = LanguageID(rstHighlightRegex.FindStringSubmatch(text)[1])
+		case rstCodeBlockRegex.MatchString(text):
+			i = s.codeBlock(i, rstCodeBlockRegex.FindStringSubmatch(text))
+		case isLiteralMarker(text):
+			i = s.literalBlock(i)
+		default:
+			i = s.paragraph(i)
+		}
+	}
+}
+
+// isLiteralMarker checks if a line ends a paragraph that introduces a literal block
+func isLiteralMarker(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasSuffix(trimmed, "::") && !strings.HasPrefix(trimmed, "..")
+}
+
+// paragraph skips to the last line of the paragraph starting at line i
+//
+// The directive is kept if the paragraph introduces a literal block.
+func (s *rstScanner) paragraph(i int) int {
+	end := i
+	for end+1 < len(s.lines) && !s.lines[end+1].blank() {
+		end++
+	}
+	if end > i && isLiteralMarker(s.lines[end].text) {
+		// the last line is scanned next
+		return end - 1
+	}
+	s.directive = Directive{}
+	return end
+}
+
+// codeBlock scans a code block directive in line i and returns its last line
+func (s *rstScanner) codeBlock(i int, match []string) int {
+	indent := len(match[1])
+	language := match[3]
+
+	var attributes map[string]string
+	options := i + 1
+	for ; options < len(s.lines) && !s.lines[options].blank() &&
+		internal.IndentWidth(s.lines[options].text) > indent; options++ {
+		if option := rstOptionRegex.FindStringSubmatch(s.lines[options].text); option != nil {
+			attributes = setAttribute(attributes, option[1], option[2])
+		}
+	}
+
+	directive := s.directive
+	if directive.Command == "" {
+		directive.Command = attributes["embed"]
+	}
+	s.directive = Directive{}
+
+	start, end := s.body(options, indent)
+	var block CodeBlock
+	if start == end {
+		// empty block that ends after the directive and its options
+		block = s.block(options, options, s.lines[options-1].end)
+		block.Indent = strings.Repeat(" ", indent+3)
+		if options > i+1 {
+			block.Indent = commonIndent(s.lines[i+1 : options])
+		}
+	} else {
+		block = s.block(start, end, s.lines[start].start)
+	}
+	block.Language = LanguageID(language)
+	block.Info = language
+	block.Attributes = attributes
+	block.EmbedComment = directive.Command
+	block.Ignore = directive.IgnoreNext
+	s.blocks = append(s.blocks, block)
+	return internal.Max(end, options) - 1
+}
+
+// literalBlock scans the literal block introduced by line i and returns its last line
+func (s *rstScanner) literalBlock(i int) int {
+	directive := s.directive
+	s.directive = Directive{}
+	start, end := s.body(i+1, internal.IndentWidth(s.lines[i].text))
+	if start == end {
+		return i
+	}
+	block := s.block(start, end, s.lines[start].start)
+	block.Language = s.highlight
+	block.EmbedComment = directive.Command
+	block.Ignore = directive.IgnoreNext
+	s.blocks = append(s.blocks, block)
+	return end - 1
+}
+
+// body finds the indented body that starts after the blank line at line from
+//
+// It returns the first and the last (exclusive) non-blank line of the body.
+func (s *rstScanner) body(from int, indent int) (int, int) {
+	if from >= len(s.lines) || !s.lines[from].blank() {
+		return from, from
+	}
+	start := from
+	for start < len(s.lines) && s.lines[start].blank() {
+		start++
+	}
+	end := start
+	for i := start; i < len(s.lines); i++ {
+		if s.lines[i].blank() {
+			continue
+		}
+		if internal.IndentWidth(s.lines[i].text) <= indent {
+			break
+		}
+		end = i + 1
+	}
+	if end == start {
+		return from, from
+	}
+	return start, end
+}
+
+// block creates a code block from the lines [start, end) that starts at offset
+func (s *rstScanner) block(start int, end int, offset int) CodeBlock {
+	endOffset := offset
+	if end > start {
+		endOffset = s.lines[end-1].end
+	}
+	return CodeBlock{
+		Start:     offset,
+		End:       endOffset,
+		StartLine: start + 1,
+		EndLine:   internal.Max(end, start+1),
+		Indent:    commonIndent(s.lines[start:end]),
+		Code:      s.source[offset:endOffset],
+	}
+}
@@ -0,0 +1,173 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+// documentBlock is the language, directive and content of a code block
+type documentBlock struct {
+	Language     LanguageID
+	EmbedComment string
+	Ignore       bool
+	Content      string
+}
+
+func TestExtractReStructuredTextBlocks(t *testing.T) {
+	for _, c := range []struct {
+		description string
+		source      string
+		expected    []documentBlock
+	}{
+		{
+			description: "code block directive",
+			source:      ".. code-block:: python\n\n   def greet():\n       pass\n\nText\n",
+			expected:    []documentBlock{{"python", "", false, "def greet():\n    pass\n"}},
+		},
+		{
+			description: "code block with blank lines and options",
+			source:      ".. code:: go\n   :caption: main.go\n   :embed: main.go\n\n   a\n\n   b\n\n\nText\n",
+			expected:    []documentBlock{{"go", "main.go", false, "a\n\nb\n"}},
+		},
+		{
+			description: "empty code block",
+			source:      ".. code-block:: go\n   :embed: main.go\n\nText\n",
+			expected:    []documentBlock{{"go", "main.go", false, ""}},
+		},
+		{
+			description: "embed comment",
+			source:      ".. embedme: main.go#L1-10\n\n.. sourcecode:: go\n\n  a\n",
+			expected:    []documentBlock{{"go", "main.go#L1-10", false, "a\n"}},
+		},
+		{
+			description: "ignore next comment",
+			source:      ".. embedme-ignore-next\n\n.. code-block:: go\n\n  a\n",
+			expected:    []documentBlock{{"go", "", true, "a\n"}},
+		},
+		{
+			description: "embed comment only applies to the next block",
+			source:      ".. embedme: main.go\n\nText\n\n.. code-block:: go\n\n  a\n",
+			expected:    []documentBlock{{"go", "", false, "a\n"}},
+		},
+		{
+			description: "literal blocks",
+			source: ".. highlight:: python\n\n.. embedme: greet.py\n\nExample\ncode::\n\n    print(1)\n\n" +
+				"::\n\n  x = 1\n    y = 2\nText\n",
+			expected: []documentBlock{
+				{"python", "greet.py", false, "print(1)\n"},
+				{"python", "", false, "x = 1\n  y = 2\n"},
+			},
+		},
+		{
+			description: "nested code block",
+			source:      ".. note::\n\n   .. code-block:: go\n\n      a\n\n   Text::\n",
+			expected:    []documentBlock{{"go", "", false, "a\n"}},
+		},
+		{
+			description: "no literal block without indented text",
+			source:      ".. note:: this is a note::\n\nText::\n\nMore text\n",
+			expected:    nil,
+		},
+	} {
+		var blocks []documentBlock
+		for _, block := range ExtractReStructuredTextBlocks(c.source) {
+			blocks = append(blocks, documentBlock{
+				Language:     block.Language,
+				EmbedComment: block.EmbedComment,
+				Ignore:       block.Ignore,
+				Content:      block.Content(),
+			})
+		}
+		if diff := cmp.Diff(blocks, c.expected); diff != "" {
+			t.Log(c.source)
+			t.Errorf("%s: unexpected code blocks: %s", c.description, diff)
+		}
+	}
+}
+
+func TestEmbedReStructuredText(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/greet.py", []byte(strings.TrimSpace(`
+def greet(name):
+    print(f"Hello {name}")
+
+    return name
+	`)), 0644)
+
+	options := NewDefaultOptions()
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	readme := strings.TrimSpace(`
+Usage
+=====
+
+.. embedme: greet.py
+
+.. code-block:: python
+
+   old
+
+- Item
+
+  .. code-block:: python
+     :caption: greet.py
+     :embed: greet.py
+
+Done
+	`) + "\n"
+
+	expected := strings.TrimSpace(`
+Usage
+=====
+
+.. embedme: greet.py
+
+.. code-block:: python
+
+   def greet(name):
+       print(f"Hello {name}")
+
+       return name
+
+- Item
+
+  .. code-block:: python
+     :caption: greet.py
+     :embed: greet.py
+
+     def greet(name):
+         print(f"Hello {name}")
+
+         return name
+
+Done
+	`) + "\n"
+
+	embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.rst"), "readme.rst")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(embedded, expected); diff != "" {
+		t.Fatalf("unexpected embedded document: %s", diff)
+	}
+
+	// embedding again must not change the document
+	reembedded, err := embedder.Embed([]byte(embedded), filepath.Join(workingDir, "readme.rst"), "readme.rst")
+	if err != nil {
+		t.Fatalf("failed to embed: %v", err)
+	}
+	if diff := cmp.Diff(reembedded, expected); diff != "" {
+		t.Fatalf("embedding is not stable: %s", diff)
+	}
+}
@@ -0,0 +1,24 @@
+package embedme
+
+import (
+	"strings"
+)
+
+// parseSelector splits a selector of the form "kind:name" into its parts
+//
+// Selectors without a kind (e.g. "Example_basic") have an empty kind.
+func parseSelector(selector string) (string, string) {
+	if parts := strings.SplitN(selector, ":", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return "", selector
+}
+
+// selectLines selects the lines of an embedded file using a named selector
+func selectLines(path string, content []byte, selector string) ([]string, error) {
+	kind, name := parseSelector(selector)
+	if kind == "" && name == "outline" {
+		return outline(path, content)
+	}
+	return extractSymbol(path, content, kind, name)
+}
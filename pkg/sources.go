@@ -3,11 +3,13 @@ package embedme
 import (
 	"fmt"
 	"log"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	fsutil "github.com/romnn/embedme/pkg/fs"
-	ignore "github.com/sabhiram/go-gitignore"
 	"github.com/spf13/afero"
 )
 
@@ -25,13 +27,13 @@ func (s SourceMap) ValidCount() int {
 	return valid
 }
 
-// Ignore ignores all sources that match the entries of the Gitignore
+// Ignore ignores all sources that are ignored
 //
 // Note: Ignore should be called after all sources were added
-func (s SourceMap) Ignore(gi *ignore.GitIgnore) int {
+func (s SourceMap) Ignore(ignored func(source string) bool) int {
 	before := s.ValidCount()
 	for source := range s {
-		if gi.MatchesPath(source) {
+		if ignored(source) {
 			s[source] = false
 		}
 	}
@@ -46,7 +48,7 @@ func (s SourceMap) Add(sources ...string) {
 	}
 }
 
-// Valid returns all valid (non-ignored) sources
+// Valid returns all valid (non-ignored) sources in sorted order
 func (s SourceMap) Valid() []string {
 	var valid []string
 	for source, ok := range s {
@@ -54,14 +56,34 @@ func (s SourceMap) Valid() []string {
 			valid = append(valid, source)
 		}
 	}
+	sort.Strings(valid)
 	return valid
 }
 
+var (
+	// DefaultIgnoreFiles are the names of the ignore files in each directory
+	DefaultIgnoreFiles = []string{".gitignore", ".embedmeignore"}
+	// DefaultDocuments are the patterns of the documents that are found in directories
+	DefaultDocuments = []string{"**/*.{md,markdown,rst,rest,adoc,asciidoc,asc,org,tex,latex,ipynb}"}
+)
+
 // SourceFinder ...
 type SourceFinder struct {
-	WorkingDir  string
-	Glob        bool
+	WorkingDir string
+	Glob       bool
+	// IgnoreFiles are the names of the ignore files in each directory (e.g. .gitignore)
 	IgnoreFiles []string
+	// ExcludeFiles are ignore files for the whole repository (e.g. the global
+	// excludes file of git), .git/info/exclude is always used
+	ExcludeFiles []string
+	// NoIgnore disables all ignore files
+	NoIgnore bool
+	// Documents are the patterns of the documents that are found in directories
+	Documents []string
+	// Sources are the patterns of the documents that are found if there are no patterns
+	Sources []string
+	// SourcesDir is the directory of the sources (the working directory by default)
+	SourcesDir string
 }
 
 // NewSourceFinder ...
@@ -69,67 +91,234 @@ func NewSourceFinder() SourceFinder {
 	return SourceFinder{
 		WorkingDir:  "",
 		Glob:        true,
-		IgnoreFiles: []string{},
+		IgnoreFiles: DefaultIgnoreFiles,
+		Documents:   DefaultDocuments,
 	}
 }
 
-// GlobFiles ...
+// GlobFiles returns the sorted files in the working directory that match the patterns
+//
+// Files that match negated patterns (e.g. !vendor/**) are excluded.
 func GlobFiles(fs afero.Fs, workingDir string, patterns ...string) ([]string, error) {
-	files := []string{}
-	dirFS := fsutil.DirFS(fs, workingDir)
-	for _, pattern := range patterns {
-		matches, err := fsutil.Glob(dirFS, pattern)
-		if err != nil {
-			return files, fmt.Errorf(
-				"failed to glob pattern %q in %s: %v",
-				pattern, workingDir, err,
-			)
-		}
-		files = append(files, matches...)
+	return globFiles(fs, workingDir, fsutil.DefaultIgnore, patterns...)
+}
+
+func globFiles(
+	fs afero.Fs,
+	workingDir string,
+	ignore func(path string, dir bool) bool,
+	patterns ...string,
+) ([]string, error) {
+	globber := fsutil.Globber{Ignore: ignore}
+	files, err := globber.Glob(fsutil.DirFS(fs, workingDir), patterns...)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to glob patterns %q in %s: %v",
+			patterns, workingDir, err,
+		)
 	}
 	return files, nil
 }
 
-// FindSources ...
+// FindSources finds the sources that match the patterns
+//
+// Directories are searched for the documents of the finder.
+// If there are no patterns, the sources of the finder are used
+// or the working directory is searched.
+// Sources that are ignored by the ignore files of their directory or
+// its parents up to the root of the git repository are marked as ignored.
+// Directories that are ignored are not searched.
 func (f *SourceFinder) FindSources(fs afero.Fs, patterns ...string) (SourceMap, error) {
 	sources := make(SourceMap)
-	for _, pattern := range patterns {
-		if f.Glob {
-			matches, err := GlobFiles(fs, f.WorkingDir, pattern)
-			if err != nil {
-				return sources, err
-			}
-			sources.Add(matches...)
-		} else {
-			if !filepath.IsAbs(pattern) {
-				pattern = filepath.Join(f.WorkingDir, pattern)
+	gi, err := f.gitIgnore(fs)
+	if err != nil {
+		return sources, err
+	}
+	if len(patterns) == 0 && len(f.Sources) > 0 {
+		err = f.addSources(fs, gi, sources)
+	} else {
+		err = f.addPatterns(fs, gi, sources, patterns)
+	}
+	if err != nil {
+		return sources, err
+	}
+	if gi == nil {
+		return sources, nil
+	}
+
+	ignored := sources.Ignore(func(source string) bool {
+		if !f.Glob {
+			// sources are relative to the current directory
+			if abs, err := filepath.Abs(source); err == nil {
+				source = abs
 			}
-			sources.Add(pattern)
 		}
+		return gi.Ignored(source, false)
+	})
+	if ignored > 0 {
+		Info(
+			log.Writer(),
+			"Skipped %d ignored file(s)\n",
+			ignored,
+		)
+	}
+	return sources, gi.Err()
+}
+
+// addSources adds the sources of the finder relative to the sources directory
+func (f *SourceFinder) addSources(fs afero.Fs, gi *fsutil.GitIgnore, sources SourceMap) error {
+	dir := f.SourcesDir
+	if dir == "" {
+		dir = f.WorkingDir
 	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	matches, err := globFiles(fs, dir, f.pruneIgnored(gi, dir), f.Sources...)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		sources.Add(filepath.Join(dir, match))
+	}
+	return nil
+}
 
-	for _, ignoreFile := range f.IgnoreFiles {
-		ignorePath := filepath.Join(f.WorkingDir, ignoreFile)
-		if _, err := fs.Stat(ignorePath); err != nil {
-			return sources, err
+// addPatterns adds the sources that match patterns or are in directories
+func (f *SourceFinder) addPatterns(
+	fs afero.Fs,
+	gi *fsutil.GitIgnore,
+	sources SourceMap,
+	patterns []string,
+) error {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		dir := pattern
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(f.WorkingDir, dir)
+		}
+		if info, err := fs.Stat(dir); err != nil || !info.IsDir() {
+			files = append(files, pattern)
+			continue
+		}
+		matches, err := globFiles(fs, dir, f.pruneIgnored(gi, dir), f.documents()...)
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			sources.Add(f.sourcePath(filepath.Join(pattern, match)))
 		}
+	}
 
-		ignoreContent, err := afero.ReadFile(fs, ignorePath)
+	if f.Glob && len(files) > 0 {
+		matches, err := globFiles(fs, f.WorkingDir, f.pruneIgnored(gi, f.WorkingDir), files...)
 		if err != nil {
-			return sources, err
+			return err
+		}
+		sources.Add(matches...)
+	} else {
+		for _, file := range files {
+			sources.Add(f.sourcePath(file))
 		}
-		ignoreLines := strings.Split(string(ignoreContent), "\n")
+	}
+	return nil
+}
+
+// documents returns the patterns of the documents that are found in directories
+func (f *SourceFinder) documents() []string {
+	if len(f.Documents) == 0 {
+		return DefaultDocuments
+	}
+	return f.Documents
+}
+
+// sourcePath returns the key of a path relative to the working directory in the source map
+//
+// Globbed sources are relative to the working directory,
+// other sources are joined with the working directory.
+func (f *SourceFinder) sourcePath(path string) string {
+	if f.Glob || filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(f.WorkingDir, path)
+}
+
+// pruneIgnored returns a function that prunes ignored directories while globbing in a directory
+func (f *SourceFinder) pruneIgnored(gi *fsutil.GitIgnore, dir string) func(path string, dir bool) bool {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		root = dir
+	}
+	return func(path string, isDir bool) bool {
+		if fsutil.DefaultIgnore(path, isDir) {
+			return true
+		}
+		return isDir && gi != nil && gi.Ignored(filepath.Join(root, filepath.FromSlash(path)), true)
+	}
+}
 
-		ignore := ignore.CompileIgnoreLines(ignoreLines...)
-		ignored := sources.Ignore(ignore)
+// gitIgnore returns the ignore rules of the git repository of the working directory
+func (f *SourceFinder) gitIgnore(fs afero.Fs) (*fsutil.GitIgnore, error) {
+	if f.NoIgnore {
+		return nil, nil
+	}
+	dir, err := filepath.Abs(f.WorkingDir)
+	if err != nil {
+		return nil, err
+	}
+	root := repositoryRoot(fs, dir)
+	gi, err := fsutil.NewGitIgnore(fs, root, dir, f.IgnoreFiles...)
+	if err != nil {
+		return nil, err
+	}
+	excludeFiles := append([]string{}, f.ExcludeFiles...)
+	excludeFiles = append(excludeFiles, filepath.Join(root, ".git", "info", "exclude"))
+	for _, excludeFile := range excludeFiles {
+		if err := gi.AddFile(excludeFile); err != nil {
+			return nil, err
+		}
+	}
+	return gi, nil
+}
 
-		if ignored > 0 {
-			Info(
-				log.Writer(),
-				"Skipped %d file(s) ignored in %s\n",
-				ignored, ignoreFile,
-			)
+// repositoryRoot returns the root of the git repository of a directory
+//
+// The directory is the root if it is not in a git repository.
+func repositoryRoot(fs afero.Fs, dir string) string {
+	for current := dir; ; {
+		if _, err := fs.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
 		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// GlobalExcludesFile returns the path of the global excludes file of git
+//
+// The file is configured with core.excludesFile in the repository of dir
+// and defaults to $XDG_CONFIG_HOME/git/ignore or ~/.config/git/ignore.
+func GlobalExcludesFile(dir string) string {
+	cmd := exec.Command("git", "config", "--path", "--get", "core.excludesFile")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if path := strings.TrimSpace(string(out)); err == nil && path != "" {
+		return path
+	}
+	if config := os.Getenv("XDG_CONFIG_HOME"); config != "" {
+		return filepath.Join(config, "git", "ignore")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
-	return sources, nil
+	return filepath.Join(home, ".config", "git", "ignore")
 }
@@ -0,0 +1,131 @@
+package embedme
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestFindSourcesGitIgnore(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/.git/info/exclude":    "local.md\n",
+		"/repo/.gitignore":           "generated/\n",
+		"/repo/docs/.gitignore":      "/draft.md\n",
+		"/repo/docs/.embedmeignore":  "nested/*.md\n!nested/keep.md\n",
+		"/repo/docs/readme.md":       "",
+		"/repo/docs/local.md":        "",
+		"/repo/docs/draft.md":        "",
+		"/repo/docs/nested/draft.md": "",
+		"/repo/docs/nested/other.md": "",
+		"/repo/docs/nested/keep.md":  "",
+		"/repo/docs/generated/a.md":  "",
+		"/home/excludes":             "*.bak.md\n",
+		"/repo/docs/notes.bak.md":    "",
+	}
+	for file, content := range files {
+		afero.WriteFile(fs, file, []byte(content), 0644)
+	}
+
+	finder := NewSourceFinder()
+	finder.WorkingDir = "/repo/docs"
+	finder.ExcludeFiles = []string{"/home/excludes"}
+	sources, err := finder.FindSources(fs, "**/*.md")
+	if err != nil {
+		t.Fatalf("failed to find sources: %v", err)
+	}
+	expected := SourceMap{
+		"draft.md":        false,
+		"local.md":        false,
+		"nested/draft.md": false,
+		"nested/keep.md":  true,
+		"nested/other.md": false,
+		"notes.bak.md":    false,
+		"readme.md":       true,
+	}
+	if diff := cmp.Diff(expected, sources); diff != "" {
+		t.Fatalf("unexpected sources: %s", diff)
+	}
+
+	finder.NoIgnore = true
+	sources, err = finder.FindSources(fs, "**/*.md")
+	if err != nil {
+		t.Fatalf("failed to find sources: %v", err)
+	}
+	if valid := sources.ValidCount(); valid != 8 {
+		t.Fatalf("expected 8 sources without ignore files but got %d: %v", valid, pretty(sources))
+	}
+}
+
+func TestFindSourcesInDirectories(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, file := range []string{
+		"/work/README.md",
+		"/work/main.go",
+		"/work/docs/guide.rst",
+		"/work/docs/intro.md",
+		"/work/docs/code.py",
+		"/work/docs/api/index.adoc",
+		"/work/node_modules/pkg/README.md",
+	} {
+		afero.WriteFile(fs, file, []byte(""), 0644)
+	}
+
+	finder := NewSourceFinder()
+	finder.WorkingDir = "/work"
+	finder.Glob = true
+
+	sources, err := finder.FindSources(fs)
+	if err != nil {
+		t.Fatalf("failed to find sources: %v", err)
+	}
+	expected := []string{"README.md", "docs/api/index.adoc", "docs/guide.rst", "docs/intro.md"}
+	if diff := cmp.Diff(expected, sources.Valid()); diff != "" {
+		t.Fatalf("unexpected sources without patterns: %s", diff)
+	}
+
+	sources, err = finder.FindSources(fs, "docs/", "main.go")
+	if err != nil {
+		t.Fatalf("failed to find sources: %v", err)
+	}
+	expected = []string{"docs/api/index.adoc", "docs/guide.rst", "docs/intro.md", "main.go"}
+	if diff := cmp.Diff(expected, sources.Valid()); diff != "" {
+		t.Fatalf("unexpected sources in directory: %s", diff)
+	}
+
+	finder.Glob = false
+	finder.Documents = []string{"**/*.md"}
+	sources, err = finder.FindSources(fs, "docs")
+	if err != nil {
+		t.Fatalf("failed to find sources: %v", err)
+	}
+	expected = []string{"/work/docs/intro.md"}
+	if diff := cmp.Diff(expected, sources.Valid()); diff != "" {
+		t.Fatalf("unexpected sources in directory: %s", diff)
+	}
+}
+
+func TestGlobalExcludesFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skipf("git is not installed: %v", err)
+	}
+	dir := t.TempDir()
+	excludes := filepath.Join(dir, "excludes")
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "core.excludesFile", excludes},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	// the excludes file is configured in the repository of the directory
+	if excludesFile := GlobalExcludesFile(dir); excludesFile != excludes {
+		t.Fatalf("expected excludes file %q but got %q", excludes, excludesFile)
+	}
+}
@@ -0,0 +1,69 @@
+package embedme
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// SymbolExtractor extracts named symbols from source files
+//
+// Symbols are selected by kind and name, e.g. "#def:greet" selects
+// the Python function greet.
+type SymbolExtractor interface {
+	// Extract returns the lines of the symbol with the given kind and name
+	Extract(path string, content []byte, kind string, name string) ([]string, error)
+}
+
+var (
+	// SymbolExtractors are the registered symbol extractors for each language
+	SymbolExtractors = map[LanguageID]SymbolExtractor{}
+	// symbolHeaderRegexes caches the header expressions for kinds and names of symbols
+	symbolHeaderRegexes sync.Map
+)
+
+func init() {
+	RegisterSymbolExtractor(GoSymbolExtractor{}, Languages.Aliases("Go")...)
+	RegisterSymbolExtractor(PythonSymbolExtractor{}, Languages.Aliases("Python")...)
+	RegisterSymbolExtractor(RustSymbolExtractor, Languages.Aliases("Rust")...)
+	RegisterSymbolExtractor(
+		TypescriptSymbolExtractor,
+		Languages.Aliases("TypeScript", "JavaScript", "TSX", "JSX")...,
+	)
+	RegisterSymbolExtractor(ProtobufSymbolExtractor, Languages.Aliases("Protocol Buffers")...)
+}
+
+// RegisterSymbolExtractor registers a symbol extractor for languages
+func RegisterSymbolExtractor(extractor SymbolExtractor, languages ...LanguageID) {
+	for _, lang := range languages {
+		SymbolExtractors[lang] = extractor
+	}
+}
+
+// extractSymbol extracts a symbol using the extractor registered for the language of a file
+func extractSymbol(path string, content []byte, kind string, name string) ([]string, error) {
+	lang := languageForPath(path)
+	extractor, ok := SymbolExtractors[lang]
+	if !ok {
+		return nil, fmt.Errorf("no symbol extractor for %s files (%s)", lang, path)
+	}
+	return extractor.Extract(path, content, kind, name)
+}
+
+// symbolNotFound is returned when a symbol does not exist
+func symbolNotFound(path string, kind string, name string) error {
+	return fmt.Errorf("%s %s not found in %s", kind, name, path)
+}
+
+// symbolHeaderRegex returns the expression for the header of a symbol
+//
+// The header must contain a single %s placeholder for the name of the symbol.
+func symbolHeaderRegex(header string, name string) *regexp.Regexp {
+	key := [2]string{header, name}
+	if re, ok := symbolHeaderRegexes.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(fmt.Sprintf(header, regexp.QuoteMeta(name)))
+	symbolHeaderRegexes.Store(key, re)
+	return re
+}
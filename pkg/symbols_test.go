@@ -0,0 +1,366 @@
+package embedme
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+const goFixture = `
+package greet
+
+// Greeter greets people
+type Greeter struct {
+	Name string
+}
+
+// Greet greets a person
+func (g *Greeter) Greet(name string) {
+	fmt.Printf("%s greets %s\n", g.Name, name)
+}
+`
+
+const pythonFixture = `
+import os
+
+@decorator
+def greet(name):
+    print(f"Hello {name}")
+
+
+class Client:
+    """A client
+
+def not_a_method():
+    """
+
+    def __init__(self, name):
+        self.name = name
+
+    def greet(
+        self,
+        other,
+    ):
+        # greet someone
+        print(f"{self.name} greets {other}")
+
+def after():
+    pass
+`
+
+const rustFixture = `
+use std::fmt;
+
+/// Greets someone
+#[inline]
+pub fn greet(name: &str) {
+    println!("Hello {}", name);
+}
+
+pub struct Client<'a> {
+    name: &'a str,
+}
+
+impl<'a> Client<'a> {
+    pub fn greet(&self, other: &str) {
+        let brace = '{';
+        println!("{} greets {} }}", self.name, other);
+    }
+}
+
+impl fmt::Display for Client<'_> {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{}", self.name)
+    }
+}
+
+pub type Name = String;
+`
+
+const typescriptFixture = `
+import { format } from "util";
+
+/**
+ * Greets someone
+ */
+export function greet(name: string): void {
+  console.log(` + "`Hello ${name}`" + `);
+}
+
+export interface Greeter {
+  greet(name: string): void;
+}
+
+export type Name = string
+
+export class Client implements Greeter {
+  constructor(private name: Name) {}
+
+  @log
+  async greet(other: string) {
+    // } in a comment
+    console.log("}", format("%s greets %s", this.name, other));
+  }
+}
+`
+
+const protobufFixture = `
+syntax = "proto3";
+
+// A user
+message User {
+  string name = 1;
+  message Address {
+    string street = 1; // the { street }
+  }
+  Address address = 2;
+}
+
+service Users {
+  rpc GetUser(GetUserRequest) returns (User);
+}
+`
+
+func TestSymbolExtractors(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	workingDir := "/work/dir"
+	fs.MkdirAll(workingDir, 0755)
+
+	afero.WriteFile(fs, "/work/dir/code/greet.go", []byte(goFixture), 0644)
+	afero.WriteFile(fs, "/work/dir/code/greet.py", []byte(pythonFixture), 0644)
+	afero.WriteFile(fs, "/work/dir/code/greet.rs", []byte(rustFixture), 0644)
+	afero.WriteFile(fs, "/work/dir/code/greet.ts", []byte(typescriptFixture), 0644)
+	afero.WriteFile(fs, "/work/dir/code/user.proto", []byte(protobufFixture), 0644)
+
+	options := NewDefaultOptions()
+	options.Base = filepath.Join(workingDir, "code")
+	options.WorkingDir = workingDir
+	embedder := Embedder{
+		Options: options,
+		FS:      fs,
+	}
+
+	for _, c := range []struct {
+		description string
+		language    LanguageID
+		embed       string
+		expected    string
+	}{
+		{
+			description: "Go method with doc comment",
+			language:    "go",
+			embed:       "greet.go#func:Greeter.Greet",
+			expected: `
+// Greet greets a person
+func (g *Greeter) Greet(name string) {
+	fmt.Printf("%s greets %s\n", g.Name, name)
+}
+`,
+		},
+		{
+			description: "Go type",
+			language:    "go",
+			embed:       "greet.go#type:Greeter",
+			expected: `
+// Greeter greets people
+type Greeter struct {
+	Name string
+}
+`,
+		},
+		{
+			description: "Go type without kind",
+			language:    "go",
+			embed:       "greet.go#Greeter",
+			expected: `
+// Greeter greets people
+type Greeter struct {
+	Name string
+}
+`,
+		},
+		{
+			description: "Go method without kind",
+			language:    "go",
+			embed:       "greet.go#Greeter.Greet",
+			expected: `
+// Greet greets a person
+func (g *Greeter) Greet(name string) {
+	fmt.Printf("%s greets %s\n", g.Name, name)
+}
+`,
+		},
+		{
+			description: "Python function with decorator",
+			language:    "python",
+			embed:       "greet.py#def:greet",
+			expected: `
+@decorator
+def greet(name):
+    print(f"Hello {name}")
+`,
+		},
+		{
+			description: "Python class with docstring",
+			language:    "python",
+			embed:       "greet.py#class:Client",
+			expected: `
+class Client:
+    """A client
+
+def not_a_method():
+    """
+
+    def __init__(self, name):
+        self.name = name
+
+    def greet(
+        self,
+        other,
+    ):
+        # greet someone
+        print(f"{self.name} greets {other}")
+`,
+		},
+		{
+			description: "Python method with multiline signature",
+			language:    "python",
+			embed:       "greet.py#def:Client.greet",
+			expected: `
+def greet(
+    self,
+    other,
+):
+    # greet someone
+    print(f"{self.name} greets {other}")
+`,
+		},
+		{
+			description: "Rust function with doc comment and attribute",
+			language:    "rust",
+			embed:       "greet.rs#fn:greet",
+			expected: `
+/// Greets someone
+#[inline]
+pub fn greet(name: &str) {
+    println!("Hello {}", name);
+}
+`,
+		},
+		{
+			description: "Rust impl block with lifetimes and brace literals",
+			language:    "rust",
+			embed:       "greet.rs#impl:Client",
+			expected: `
+impl<'a> Client<'a> {
+    pub fn greet(&self, other: &str) {
+        let brace = '{';
+        println!("{} greets {} }}", self.name, other);
+    }
+}
+`,
+		},
+		{
+			description: "Rust trait impl",
+			language:    "rust",
+			embed:       "greet.rs#fn:Display.fmt",
+			expected: `
+fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+    write!(f, "{}", self.name)
+}
+`,
+		},
+		{
+			description: "Rust type alias",
+			language:    "rust",
+			embed:       "greet.rs#type:Name",
+			expected: `
+pub type Name = String;
+`,
+		},
+		{
+			description: "Typescript function with doc comment",
+			language:    "ts",
+			embed:       "greet.ts#function:greet",
+			expected: `
+/**
+ * Greets someone
+ */
+export function greet(name: string): void {
+  console.log(` + "`Hello ${name}`" + `);
+}
+`,
+		},
+		{
+			description: "Typescript type without semicolon",
+			language:    "ts",
+			embed:       "greet.ts#type:Name",
+			expected: `
+export type Name = string
+`,
+		},
+		{
+			description: "Typescript method with decorator",
+			language:    "ts",
+			embed:       "greet.ts#method:Client.greet",
+			expected: `
+@log
+async greet(other: string) {
+  // } in a comment
+  console.log("}", format("%s greets %s", this.name, other));
+}
+`,
+		},
+		{
+			description: "Protobuf message with nested message",
+			language:    "proto",
+			embed:       "user.proto#message:User",
+			expected: `
+// A user
+message User {
+  string name = 1;
+  message Address {
+    string street = 1; // the { street }
+  }
+  Address address = 2;
+}
+`,
+		},
+		{
+			description: "Protobuf nested message",
+			language:    "proto",
+			embed:       "user.proto#message:User.Address",
+			expected: `
+message Address {
+  string street = 1; // the { street }
+}
+`,
+		},
+		{
+			description: "Protobuf rpc",
+			language:    "proto",
+			embed:       "user.proto#rpc:GetUser",
+			expected: `
+rpc GetUser(GetUserRequest) returns (User);
+`,
+		},
+	} {
+		readme := "<!-- embedme " + c.embed + " -->\n```" + string(c.language) + "\n```\n"
+		embedded, err := embedder.Embed([]byte(readme), filepath.Join(workingDir, "readme.md"), "readme.md")
+		if err != nil {
+			t.Fatalf("%s: failed to embed: %v", c.description, err)
+		}
+		expected := "<!-- embedme " + c.embed + " -->\n```" + string(c.language) + "\n" +
+			strings.TrimPrefix(c.expected, "\n") + "```\n"
+
+		equal := cmp.Equal(embedded, expected)
+		diff := cmp.Diff(embedded, expected)
+		if !equal {
+			t.Logf("expected: %s", expected)
+			t.Fatalf("%s: unexpected symbol: %s", c.description, diff)
+		}
+	}
+}